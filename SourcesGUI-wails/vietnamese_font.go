@@ -183,7 +183,10 @@ func validateVietnameseSetFiles(set vietnameseFontSet) error {
 }
 
 func (a *App) patchVietnameseSet(set vietnameseFontSet, chars string, ttfBytes []byte, outputDir string, yOffset int, redrawLatin bool) error {
-	infoPak := pak.LoadPak(set.InfoPak, charset.UTF_8)
+	infoPak, err := pak.Open(set.InfoPak, charset.UTF_8)
+	if err != nil {
+		return err
+	}
 	infoPak.ReadAll()
 	if len(infoPak.Files) == 0 {
 		return fmt.Errorf("empty info PAK: %s", set.InfoPak)
@@ -226,7 +229,10 @@ func (a *App) patchVietnameseSet(set vietnameseFontSet, chars string, ttfBytes [
 
 	var patchedInfos [][]byte
 	for _, familyPakName := range set.FamilyPaks {
-		familyPak := pak.LoadPak(familyPakName, charset.UTF_8)
+		familyPak, err := pak.Open(familyPakName, charset.UTF_8)
+		if err != nil {
+			return err
+		}
 		familyPak.ReadAll()
 		if len(familyPak.Files) != len(infoPak.Files) {
 			return fmt.Errorf("file count mismatch: %s has %d, %s has %d",
@@ -37,3 +37,35 @@ func init() {
 	imageCmd.MarkPersistentFlagRequired("output")
 	imageCmd.MarkFlagsRequiredTogether("output", "input")
 }
+
+// localImageFlags 为子命令定义本地的input、output与source
+//
+//	Description 本地flag覆盖imageCmd的同名persistent flag，
+//	  用于不同时需要input与output的子命令，required中的flag由cobra检查
+//	Param cmd *cobra.Command
+//	Param required ...string 必需的flag
+func localImageFlags(cmd *cobra.Command, required ...string) {
+	cmd.Flags().StringVarP(&CzSource, "source", "s", "", "原cz文件名")
+	cmd.Flags().StringVarP(&CzInput, "input", "i", "", "输入文件")
+	cmd.Flags().StringVarP(&CzOutput, "output", "o", "", "输出文件")
+	for _, name := range required {
+		cmd.MarkFlagRequired(name)
+	}
+}
+
+// inputArg input可以由--input或唯一的位置参数给出
+func inputArg(cmd *cobra.Command, args []string) error {
+	if err := cobra.MaximumNArgs(1)(cmd, args); err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		if len(CzInput) != 0 {
+			return fmt.Errorf("input given both as --input and as an argument")
+		}
+		CzInput = args[0]
+	}
+	if len(CzInput) == 0 {
+		return fmt.Errorf("required flag(s) \"input\" not set")
+	}
+	return nil
+}
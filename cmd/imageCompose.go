@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"lucksystem/charset"
+	"lucksystem/czimage"
+	"lucksystem/pak"
+
+	"github.com/spf13/cobra"
+)
+
+// imageComposeCmd represents the image compose command
+var imageComposeCmd = &cobra.Command{
+	Use:   "compose <entry>...",
+	Short: "Stack CZ sprite parts into a layered OpenRaster (.ora) document",
+	Long: `Place CZ entries (a base body first, then expression/face overlays) at the X/Y
+offsets stored in their headers and write them as layers of an OpenRaster
+document, which Krita and GIMP open with the parts correctly aligned.
+
+Entries are read from --source, a folder or a PAK. Layers are named after the
+entries and stacked in the given order, the first one at the bottom. Use
+"image split" to write an edited document back to CZ files.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		read, closeSource, err := openCzSource(CzSource)
+		if err != nil {
+			return err
+		}
+		defer closeSource()
+		layers := make([]*czimage.Layer, len(args))
+		for i, entry := range args {
+			data, err := read(entry)
+			if err != nil {
+				return err
+			}
+			cz, err := czimage.ReadCzImage(data)
+			if err != nil {
+				return fmt.Errorf("%s: %w", entry, err)
+			}
+			if layers[i], err = czimage.CzLayer(entry, cz); err != nil {
+				return fmt.Errorf("%s: %w", entry, err)
+			}
+			fmt.Printf("%s: %dx%d at %d,%d\n", entry, layers[i].Image.Rect.Dx(), layers[i].Image.Rect.Dy(), layers[i].X, layers[i].Y)
+		}
+		out, err := os.Create(CzOutput)
+		if err != nil {
+			return err
+		}
+		err = czimage.WriteORA(out, layers)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(CzOutput)
+			return err
+		}
+		fmt.Printf("Composed %d layer(s) into %s\n", len(layers), CzOutput)
+		return nil
+	},
+}
+
+// imageSplitCmd represents the image split command
+var imageSplitCmd = &cobra.Command{
+	Use:   "split",
+	Short: "Write the layers of an edited OpenRaster document back to CZ files",
+	Long: `Read an OpenRaster document made by "image compose" and import every layer into
+the CZ entry of the same name from --source (a folder or a PAK), writing the
+result to the --output folder.
+
+Each CZ keeps its size and X/Y offset: layer pixels are taken from the CZ's
+rectangle on the canvas, so layers cropped or moved by the editor still line
+up. Opaque pixels outside that rectangle are dropped with a warning. Layers
+without a matching entry (e.g. helper layers) are skipped.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := os.Open(CzInput)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		fi, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		layers, err := czimage.ReadORA(f, fi.Size())
+		if err != nil {
+			return fmt.Errorf("%s: %w", CzInput, err)
+		}
+		read, closeSource, err := openCzSource(CzSource)
+		if err != nil {
+			return err
+		}
+		defer closeSource()
+
+		written := 0
+		for _, layer := range layers {
+			if !localName(layer.Name) {
+				fmt.Printf("%s: skipped, not a relative entry name\n", layer.Name)
+				continue
+			}
+			data, err := read(layer.Name)
+			if err != nil {
+				fmt.Printf("%s: skipped, no such entry in %s\n", layer.Name, CzSource)
+				continue
+			}
+			cz, err := czimage.ReadCzImage(data)
+			if err != nil {
+				return fmt.Errorf("%s: %w", layer.Name, err)
+			}
+			setCzJobs(cz, CzJobs)
+			clipped, err := czimage.ImportLayer(cz, layer)
+			if err != nil {
+				return fmt.Errorf("%s: %w", layer.Name, err)
+			}
+			if clipped > 0 {
+				m := czimage.GetMetadata(cz)
+				fmt.Printf("%s: warning: %d opaque pixel(s) outside the %dx%d image were dropped\n",
+					layer.Name, clipped, m.Width, m.Height)
+			}
+			if err = writeCz(filepath.Join(CzOutput, layer.Name), cz); err != nil {
+				return err
+			}
+			written++
+		}
+		fmt.Printf("Split %d of %d layer(s) into %s\n", written, len(layers), CzOutput)
+		return nil
+	},
+}
+
+// openCzSource 从文件夹或pak读取cz
+func openCzSource(source string) (read func(name string) ([]byte, error), closeSource func(), err error) {
+	fi, err := os.Stat(source)
+	if err != nil {
+		return nil, nil, err
+	}
+	if fi.IsDir() {
+		read = func(name string) ([]byte, error) {
+			return os.ReadFile(filepath.Join(source, name))
+		}
+		return read, func() {}, nil
+	}
+	fsys, err := pak.OpenFS(source, charset.Charset(Charset))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s is neither a folder nor a PAK: %w", source, err)
+	}
+	return fsys.ReadFile, func() { fsys.Close() }, nil
+}
+
+// localName 图层名或entry是否为不超出source与输出文件夹的相对路径
+func localName(name string) bool {
+	name = filepath.Clean(filepath.FromSlash(name))
+	return len(name) != 0 && name != "." && name != ".." && !filepath.IsAbs(name) &&
+		!strings.HasPrefix(name, ".."+string(filepath.Separator))
+}
+
+// writeCz 保存cz，失败时删除不完整的文件
+func writeCz(output string, cz czimage.CzImage) error {
+	if err := os.MkdirAll(filepath.Dir(output), os.ModePerm); err != nil {
+		return err
+	}
+	out, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	err = cz.Write(out)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(output)
+	}
+	return err
+}
+
+func init() {
+	imageCmd.AddCommand(imageComposeCmd)
+	imageCmd.AddCommand(imageSplitCmd)
+	localImageFlags(imageComposeCmd, "source", "output")
+	localImageFlags(imageSplitCmd, "input", "source", "output")
+
+	imageComposeCmd.Flags().StringVar(&Charset, "charset", string(charset.UTF_8), "PAK name charset when --source is a PAK")
+	imageSplitCmd.Flags().StringVar(&Charset, "charset", string(charset.UTF_8), "PAK name charset when --source is a PAK")
+	imageSplitCmd.Flags().IntVarP(&CzJobs, "jobs", "j", 0, "compress blocks in parallel with this many goroutines, 0 keeps the sequential block layout")
+}
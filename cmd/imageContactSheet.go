@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"lucksystem/charset"
+	"lucksystem/czimage"
+	"lucksystem/pak"
+
+	"github.com/spf13/cobra"
+)
+
+// imageContactSheetCmd represents the image contactsheet command
+var imageContactSheetCmd = &cobra.Command{
+	Use:   "contactsheet <pak>",
+	Short: "Write thumbnail sheets and an HTML index of the CZ images in a PAK",
+	Long: `Decode every CZ entry of a PAK, scale it to a thumbnail and lay the thumbnails
+out on paged PNG sheets (sheet_000.png, sheet_001.png, ...) labelled with the
+entry ID and name, so a CG can be found among thousands of numbered entries.
+
+index.html lists each entry with its ID, name, PAK offset and length, type,
+dimensions, display position and colorbits; clicking a thumbnail on a sheet
+jumps to its row.
+Entries that fail to decode are shown as red cells.`,
+	Args: inputArg,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fsys, err := pak.OpenFS(CzInput, charset.Charset(Charset))
+		if err != nil {
+			return err
+		}
+		defer fsys.Close()
+
+		var entries []*czimage.SheetEntry
+		for _, e := range fsys.Pak().Files {
+			data, err := fsys.ReadFile(e.Name)
+			if err != nil {
+				return err
+			}
+			if isCz(data) {
+				entry := czimage.NewSheetEntry(e.Name, e.ID, data, CzSheet.CellSize)
+				entry.Offset, entry.Length = e.Offset, e.Length
+				entries = append(entries, entry)
+			}
+		}
+		if len(entries) == 0 {
+			return fmt.Errorf("%s: no CZ images", CzInput)
+		}
+
+		if err = os.MkdirAll(CzOutput, os.ModePerm); err != nil {
+			return err
+		}
+		sheets := czimage.ContactSheets(entries, CzSheet)
+		pages := make([]string, len(sheets))
+		for i, sheet := range sheets {
+			pages[i] = fmt.Sprintf("sheet_%03d.png", i)
+			if err = writePng(filepath.Join(CzOutput, pages[i]), sheet); err != nil {
+				return err
+			}
+		}
+		out, err := os.Create(filepath.Join(CzOutput, "index.html"))
+		if err != nil {
+			return err
+		}
+		err = czimage.WriteSheetIndex(out, filepath.Base(CzInput), entries, pages)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+		failed := 0
+		for _, e := range entries {
+			if len(e.Error) != 0 {
+				fmt.Printf("%d %s: %s\n", e.ID, e.Name, e.Error)
+				failed++
+			}
+		}
+		fmt.Printf("%s: %d image(s) on %d sheet(s), %d failed to decode, written to %s\n",
+			CzInput, len(entries), len(sheets), failed, CzOutput)
+		return nil
+	},
+}
+
+// writePng 保存png
+func writePng(file string, img *image.NRGBA) error {
+	out, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	err = png.Encode(out, img)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+var (
+	CzSheet czimage.SheetOptions // 索引图布局
+)
+
+func init() {
+	imageCmd.AddCommand(imageContactSheetCmd)
+	localImageFlags(imageContactSheetCmd, "output")
+
+	imageContactSheetCmd.Flags().StringVar(&Charset, "charset", string(charset.UTF_8), "PAK name charset")
+	imageContactSheetCmd.Flags().IntVar(&CzSheet.Columns, "columns", 8, "thumbnails per row")
+	imageContactSheetCmd.Flags().IntVar(&CzSheet.Rows, "rows", 6, "rows per sheet")
+	imageContactSheetCmd.Flags().IntVar(&CzSheet.CellSize, "size", 160, "maximum thumbnail width and height")
+}
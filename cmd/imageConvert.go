@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"lucksystem/czimage"
+
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+)
+
+// imageConvertCmd represents the image convert command
+var imageConvertCmd = &cobra.Command{
+	Use:   "convert",
+	Short: "Convert a CZ image to another CZ type",
+	Long: `Convert a CZ image to another CZ type, e.g. a CZ1 palette image to CZ3 when
+translated text needs anti-aliasing or more colors.
+
+The image is decoded and re-encoded; position, Width1/Height1 and Colorblock
+are kept from the source. A warning is printed when the target type may not be
+accepted by the engine for this kind of asset.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := os.ReadFile(CzInput)
+		if err != nil {
+			return err
+		}
+		cz, err := czimage.ReadCzImage(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", CzInput, err)
+		}
+		m := czimage.GetMetadata(cz)
+		for _, w := range czimage.ConvertWarnings(m.Type, CzConvertTo, m.Position != nil) {
+			glog.Warningf("%s: %s -> %s: %s\n", CzInput, m.Type, CzConvertTo, w)
+			fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+		}
+		out, err := czimage.Convert(cz, czimage.EncodeOptions{
+			Type:      CzConvertTo,
+			Colorbits: CzEncode.Colorbits,
+			BlockSize: CzEncode.BlockSize,
+			Jobs:      CzEncode.Jobs,
+			Dither:    CzEncode.Dither,
+		})
+		if err != nil {
+			return err
+		}
+		f, err := os.Create(CzOutput)
+		if err != nil {
+			return err
+		}
+		err = out.Write(f)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(CzOutput)
+			return err
+		}
+		fmt.Printf("Converted %s: %s -> %s\n", CzOutput, m.Type, CzConvertTo)
+		return nil
+	},
+}
+
+var (
+	CzConvertTo string // 目标cz类型
+)
+
+func init() {
+	imageCmd.AddCommand(imageConvertCmd)
+
+	imageConvertCmd.Flags().StringVar(&CzConvertTo, "to", "cz3", "target CZ type: cz0, cz1, cz2, cz3 or cz4")
+	imageConvertCmd.Flags().Uint16Var(&CzEncode.Colorbits, "colorbits", 0, "color bits of the target (cz1: 4, 8, 24 or 32), 0 uses the default")
+	imageConvertCmd.Flags().IntVar(&CzEncode.BlockSize, "block_size", 0, "compressed block size, 0 uses the default")
+	imageConvertCmd.Flags().IntVarP(&CzEncode.Jobs, "jobs", "j", 0, "compress blocks in parallel with this many goroutines, 0 compresses sequentially")
+	imageConvertCmd.Flags().BoolVar(&CzEncode.Dither, "dither", false, "Floyd-Steinberg dithering for 4/8-bit cz1")
+}
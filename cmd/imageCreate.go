@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"image/png"
+	"os"
+
+	"lucksystem/czimage"
+
+	"github.com/spf13/cobra"
+)
+
+// imageCreateCmd represents the image create command
+var imageCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a CZ image from a PNG without a source CZ",
+	Long: `Create a new CZ0/CZ1/CZ2/CZ3/CZ4 image from a PNG, without a source CZ.
+
+The display position (--x/--y) and the Width1/Height1 fields of the extended
+header are written as given, so new UI graphics and title logos can be placed
+on screen. CZ2 only stores the alpha channel (font atlases).`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := os.Open(CzInput)
+		if err != nil {
+			return err
+		}
+		img, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		cz, err := czimage.Encode(img, CzEncode)
+		if err != nil {
+			return err
+		}
+		out, err := os.Create(CzOutput)
+		if err != nil {
+			return err
+		}
+		err = cz.Write(out)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(CzOutput)
+			return err
+		}
+		b := img.Bounds()
+		fmt.Printf("Created %s: %s %dx%d\n", CzOutput, CzEncode.Type, b.Dx(), b.Dy())
+		return nil
+	},
+}
+
+var (
+	CzEncode czimage.EncodeOptions // 新建cz的参数
+)
+
+func init() {
+	imageCmd.AddCommand(imageCreateCmd)
+
+	imageCreateCmd.Flags().StringVarP(&CzEncode.Type, "type", "t", "cz3", "CZ type: cz0, cz1, cz2, cz3 or cz4")
+	imageCreateCmd.Flags().Uint16Var(&CzEncode.Colorbits, "colorbits", 0, "color bits (cz1: 4, 8, 24 or 32), 0 uses 8 for cz2 and 32 otherwise")
+	imageCreateCmd.Flags().IntVar(&CzEncode.BlockSize, "block_size", 0, "compressed block size, 0 uses the default")
+	imageCreateCmd.Flags().IntVarP(&CzEncode.Jobs, "jobs", "j", 0, "compress blocks in parallel with this many goroutines, 0 compresses sequentially")
+	imageCreateCmd.Flags().Uint8Var(&CzEncode.Flag, "flag", 0, "flag byte of the extended header")
+	imageCreateCmd.Flags().Uint16Var(&CzEncode.X, "x", 0, "display X position")
+	imageCreateCmd.Flags().Uint16Var(&CzEncode.Y, "y", 0, "display Y position")
+	imageCreateCmd.Flags().Uint16Var(&CzEncode.Width1, "width1", 0, "Width1 of the extended header, 0 uses the image width")
+	imageCreateCmd.Flags().Uint16Var(&CzEncode.Height1, "height1", 0, "Height1 of the extended header, 0 uses the image height")
+	imageCreateCmd.Flags().BoolVar(&CzEncode.Dither, "dither", false, "Floyd-Steinberg dithering for 4/8-bit cz1")
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"lucksystem/czimage"
+
+	"github.com/spf13/cobra"
+)
+
+// imageDiffCmd represents the image diff command
+var imageDiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Compare two folders of CZ images and write an HTML report",
+	Long: `Decode every CZ image under --orig and --new (searched recursively, matched by
+relative path), compare pixels and header fields and write a static HTML report
+with thumbnails of the original, the new image and the changed regions.
+
+Width/height, position and colorbits changes are listed per image; a size
+change is flagged because importing such a PNG into the original CZ is
+rejected. Images only present on one side are reported as added or removed.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		results, err := diffImages(CzOrig, CzNew)
+		if err != nil {
+			return err
+		}
+		out, err := os.Create(CzOutput)
+		if err != nil {
+			return err
+		}
+		err = czimage.WriteDiffReport(out, fmt.Sprintf("%s → %s", CzOrig, CzNew), results)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+		counts := make(map[string]int)
+		for _, r := range results {
+			counts[r.Status]++
+			if r.Status != czimage.DiffSame || CzVerbose {
+				fmt.Println(r)
+			}
+		}
+		fmt.Printf("%d image(s): %d changed, %d added, %d removed, %d error(s); report written to %s\n",
+			len(results), counts[czimage.DiffChanged], counts[czimage.DiffAdded], counts[czimage.DiffRemoved],
+			counts[czimage.DiffError], CzOutput)
+		return nil
+	},
+}
+
+// diffImages 按相对路径比较两个文件夹中的cz
+func diffImages(origDir, newDir string) ([]*czimage.DiffResult, error) {
+	origFiles, err := czFiles(origDir)
+	if err != nil {
+		return nil, err
+	}
+	newFiles, err := czFiles(newDir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range origFiles {
+		names = append(names, name)
+	}
+	for name := range newFiles {
+		if _, ok := origFiles[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	results := make([]*czimage.DiffResult, 0, len(names))
+	for _, name := range names {
+		var origData, newData []byte
+		if path, ok := origFiles[name]; ok {
+			if origData, err = os.ReadFile(path); err != nil {
+				return nil, err
+			}
+		}
+		if path, ok := newFiles[name]; ok {
+			if newData, err = os.ReadFile(path); err != nil {
+				return nil, err
+			}
+		}
+		results = append(results, czimage.DiffCz(name, origData, newData))
+	}
+	return results, nil
+}
+
+// czFiles 文件夹中的cz文件，相对路径 -> 路径
+func czFiles(dir string) (map[string]string, error) {
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if !isCz(data) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = path
+		return nil
+	})
+	return files, err
+}
+
+var (
+	CzOrig string // 原图像文件夹
+	CzNew  string // 新图像文件夹
+)
+
+func init() {
+	imageCmd.AddCommand(imageDiffCmd)
+	localImageFlags(imageDiffCmd, "output")
+
+	imageDiffCmd.Flags().StringVar(&CzOrig, "orig", "", "folder with the original CZ images")
+	imageDiffCmd.Flags().StringVar(&CzNew, "new", "", "folder with the edited CZ images")
+	imageDiffCmd.MarkFlagRequired("orig")
+	imageDiffCmd.MarkFlagRequired("new")
+	imageDiffCmd.Flags().BoolVarP(&CzVerbose, "verbose", "v", false, "also print unchanged images")
+}
@@ -6,36 +6,134 @@ package cmd
 import (
 	"fmt"
 	"github.com/golang/glog"
+	"image"
+	"image/png"
 	"lucksystem/czimage"
+	"lucksystem/font"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/image/font/opentype"
 )
 
 // imageExportCmd represents the imageExport command
 var imageExportCmd = &cobra.Command{
 	Use:   "export",
-	Short: "提取cz文件到png图片",
+	Short: "提取cz文件到png/bmp/tiff图片",
+	Long: `Export a CZ image to PNG, BMP or TIFF.
+
+For CZ2 font atlases, --font_info with the matching info file (e.g. info32 for
+明朝32) and --grid write a second, enlarged PNG with the BlockSize cell grid,
+each cell's glyph index, mapped character and Unicode code. The X and Y
+offsets are green lines and the advance X+W is a vertical line (red when the
+glyph is wider). Cells with a glyph but no character are orange, characters
+without a glyph are yellow, and offsets outside the cell are reported. Pass a
+TTF/OTF with --grid_font to draw the mapped characters themselves.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("imageExport called")
-		cz := czimage.LoadCzImageFile(CzInput)
-		if cz == nil {
-			glog.Fatalf("%s is not a supported CZ image", CzInput)
+		data, err := os.ReadFile(CzInput)
+		if err != nil {
+			glog.Fatalln(err)
+		}
+		cz, err := czimage.ReadCzImage(data)
+		if err != nil {
+			glog.Fatalf("%s: %v\n", CzInput, err)
 		}
 		out, err := os.Create(CzOutput)
 		if err != nil {
 			glog.Fatalln(err)
 		}
 		defer out.Close()
-		err = cz.Export(out)
+		format := CzFormat
+		if len(format) == 0 {
+			format = czimage.FormatFromExt(CzOutput)
+		}
+		if strings.EqualFold(format, "png") {
+			err = cz.Export(out)
+		} else {
+			var img image.Image
+			if img, err = cz.GetImage(); err == nil {
+				err = czimage.EncodeFormat(out, img, format)
+			}
+		}
 		if err != nil {
 			glog.Fatalln(err)
 		}
+		if CzMeta {
+			if err = czimage.GetMetadata(cz).Save(czimage.MetaFile(CzOutput)); err != nil {
+				glog.Fatalln(err)
+			}
+		}
+		if len(CzGrid) > 0 {
+			if err = exportGlyphGrid(cz); err != nil {
+				glog.Fatalln(err)
+			}
+		}
 	},
 }
 
+// exportGlyphGrid 导出字库的调试网格图
+func exportGlyphGrid(cz czimage.CzImage) error {
+	if len(CzFontInfo) == 0 {
+		return fmt.Errorf("--grid needs the font info file (--font_info)")
+	}
+	info := font.LoadFontInfoFile(CzFontInfo)
+	img, err := cz.GetImage()
+	if err != nil {
+		return err
+	}
+	opts := font.GridOptions{Scale: CzGridScale}
+	if len(CzGridFont) > 0 {
+		data, err := os.ReadFile(CzGridFont)
+		if err != nil {
+			return err
+		}
+		f, err := opentype.Parse(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", CzGridFont, err)
+		}
+		if opts.Face, err = opentype.NewFace(f, &opentype.FaceOptions{Size: 12, DPI: 72}); err != nil {
+			return err
+		}
+		defer opts.Face.Close()
+	}
+	grid, issues := font.DrawGlyphGrid(img, info, opts)
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+	out, err := os.Create(CzGrid)
+	if err != nil {
+		return err
+	}
+	err = png.Encode(out, grid)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		fmt.Printf("Glyph grid of %d characters written to %s, %d cell(s) flagged\n", info.CharNum, CzGrid, len(issues))
+	}
+	return err
+}
+
+var (
+	CzFormat string // 导出格式
+	CzMeta   bool   // 导出、导入json头信息
+
+	CzFontInfo  string // 字库对应的info文件
+	CzGrid      string // 字库网格图输出文件
+	CzGridFont  string // 网格图中绘制字符的字体
+	CzGridScale int    // 网格图放大倍数
+)
+
 func init() {
 	imageCmd.AddCommand(imageExportCmd)
+	imageExportCmd.Flags().BoolVar(&CzMeta, "meta", false, "also write the CZ header (position, sizes, extra bytes) to <output>.json")
+	imageExportCmd.Flags().StringVar(&CzFormat, "format", "", "output format: png, bmp or tiff (default from the output extension, png otherwise)")
+	imageExportCmd.Flags().StringVar(&CzFontInfo, "font_info", "", "font info file matching a CZ2 font atlas, used by --grid")
+	imageExportCmd.Flags().StringVar(&CzGrid, "grid", "", "also write a glyph grid PNG (cells, indices, characters, widths) of a font atlas")
+	imageExportCmd.Flags().StringVar(&CzGridFont, "grid_font", "", "TTF/OTF used to draw the mapped characters on the grid")
+	imageExportCmd.Flags().IntVar(&CzGridScale, "grid_scale", 0, "enlarge glyphs on the grid by this factor, 0 picks one")
 
 	// Here you will define your flags and configuration settings.
 
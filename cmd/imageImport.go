@@ -18,7 +18,14 @@ var imageImportCmd = &cobra.Command{
 	Short: "导入png图像到cz文件中",
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("imageImport called")
-		cz := czimage.LoadCzImageFile(CzSource)
+		data, err := os.ReadFile(CzSource)
+		if err != nil {
+			glog.Fatalln(err)
+		}
+		cz, err := czimage.ReadCzImage(data)
+		if err != nil {
+			glog.Fatalf("%s: %v\n", CzSource, err)
+		}
 		out, err := os.Create(CzOutput)
 		if err != nil {
 			glog.Fatalln(err)
@@ -27,10 +34,23 @@ var imageImportCmd = &cobra.Command{
 
 		f, err := os.Open(CzInput)
 		if err != nil {
-			return
+			out.Close()
+			os.Remove(CzOutput)
+			glog.Fatalln(err)
 		}
 		defer f.Close()
+		if cz1, ok := cz.(*czimage.Cz1Image); ok {
+			cz1.RebuildPalette = CzPalette
+			cz1.Dither = CzDither
+		}
+		setCzJobs(cz, CzJobs)
 		err = cz.Import(f, Fill)
+		if err == nil && CzMeta {
+			var m *czimage.Metadata
+			if m, err = czimage.LoadMetadata(czimage.MetaFile(CzInput)); err == nil {
+				err = czimage.ApplyMetadata(cz, m)
+			}
+		}
 		if err != nil {
 			out.Close()
 			os.Remove(CzOutput)
@@ -48,12 +68,33 @@ var imageImportCmd = &cobra.Command{
 }
 
 var (
-	Fill bool // 填充为原大小，仅cz1支持
+	Fill      bool // 填充为原大小，仅cz1支持
+	CzPalette bool // 重新生成调色板，仅cz1支持
+	CzDither  bool // 抖动，仅cz1支持
+	CzJobs    int  // 并行压缩的数量
 )
 
+// setCzJobs 设置导入时并行压缩的数量
+func setCzJobs(cz czimage.CzImage, jobs int) {
+	switch c := cz.(type) {
+	case *czimage.Cz1Image:
+		c.Jobs = jobs
+	case *czimage.Cz2Image:
+		c.Jobs = jobs
+	case *czimage.Cz3Image:
+		c.Jobs = jobs
+	case *czimage.Cz4Image:
+		c.Jobs = jobs
+	}
+}
+
 func init() {
 	imageCmd.AddCommand(imageImportCmd)
 	imageImportCmd.Flags().BoolVarP(&Fill, "fill", "f", false, "图像尺寸填充为与source一致，仅支持cz1")
+	imageImportCmd.Flags().BoolVar(&CzMeta, "meta", false, "apply the CZ header from <input>.json written by export --meta")
+	imageImportCmd.Flags().BoolVar(&CzPalette, "palette", false, "rebuild the 4/8-bit palette from the PNG (median cut), cz1 only")
+	imageImportCmd.Flags().IntVarP(&CzJobs, "jobs", "j", 0, "compress blocks in parallel with this many goroutines, 0 keeps the sequential block layout")
+	imageImportCmd.Flags().BoolVar(&CzDither, "dither", false, "Floyd-Steinberg dithering when mapping to the 4/8-bit palette, cz1 only")
 	// Here you will define your flags and configuration settings.
 
 	// Cobra supports Persistent Flags which will work for this command
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"lucksystem/czimage"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/image/font/opentype"
+)
+
+// imageTypesetCmd represents the image typeset command
+var imageTypesetCmd = &cobra.Command{
+	Use:   "typeset",
+	Short: "Render translated text from a TSV into CZ UI images",
+	Long: `Render text into CZ images (menu buttons, title cards, chapter headers) from a
+TSV, so UI assets can be regenerated reproducibly instead of redrawn by hand.
+
+The TSV starts with a header row; columns may be in any order:
+  entry    CZ file, relative to --source (required)
+  text     text to draw, \n for a line break (required)
+  box      x,y,width,height of the text region (required)
+  font     TTF/OTF file, --font when empty
+  size     font size in pixels, 3/4 of the box height when empty
+  color    #RRGGBB or #RRGGBBAA, white when empty
+  outline  width[,#color], black outline when no color is given
+  align    left, center or right (default center, always vertically centered)
+  erase    empty to draw over the image, "clear" or a #color to fill the box first
+
+Text that does not fit is shrunk. Rows with the same entry are drawn into one
+image, which is re-imported into the source CZ and written to --output.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := os.Open(CzInput)
+		if err != nil {
+			return err
+		}
+		boxes, err := czimage.ReadTextBoxes(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", CzInput, err)
+		}
+
+		// 按entry分组，保持TSV中的顺序
+		var entries []string
+		groups := make(map[string][]*czimage.TextBox)
+		for _, box := range boxes {
+			if _, ok := groups[box.Entry]; !ok {
+				entries = append(entries, box.Entry)
+			}
+			groups[box.Entry] = append(groups[box.Entry], box)
+		}
+		fonts := fontLoader(CzFont)
+		for _, entry := range entries {
+			if err = typesetEntry(entry, groups[entry], fonts); err != nil {
+				return fmt.Errorf("%s: %w", entry, err)
+			}
+			fmt.Printf("Typeset %s (%d text box(es))\n", entry, len(groups[entry]))
+		}
+		fmt.Printf("Typeset %d image(s) into %s\n", len(entries), CzOutput)
+		return nil
+	},
+}
+
+// typesetEntry 在一个cz中绘制文字并写入输出文件夹
+func typesetEntry(entry string, boxes []*czimage.TextBox, fonts func(file string) (*opentype.Font, error)) error {
+	if !localName(entry) {
+		return fmt.Errorf("not a relative entry name")
+	}
+	data, err := os.ReadFile(filepath.Join(CzSource, entry))
+	if err != nil {
+		return err
+	}
+	cz, err := czimage.ReadCzImage(data)
+	if err != nil {
+		return err
+	}
+	if cz1, ok := cz.(*czimage.Cz1Image); ok {
+		cz1.RebuildPalette = CzPalette
+		cz1.Dither = CzDither
+	}
+	setCzJobs(cz, CzJobs)
+	if err = czimage.Typeset(cz, boxes, fonts); err != nil {
+		return err
+	}
+	return writeCz(filepath.Join(CzOutput, entry), cz)
+}
+
+// fontLoader 载入并缓存字体文件，file为空时使用默认字体
+func fontLoader(defaultFont string) func(file string) (*opentype.Font, error) {
+	cache := make(map[string]*opentype.Font)
+	return func(file string) (*opentype.Font, error) {
+		if len(file) == 0 {
+			file = defaultFont
+		}
+		if len(file) == 0 {
+			return nil, fmt.Errorf("no font column and no --font given")
+		}
+		if f, ok := cache[file]; ok {
+			return f, nil
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		f, err := opentype.Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		cache[file] = f
+		return f, nil
+	}
+}
+
+var (
+	CzFont string // 默认字体
+)
+
+func init() {
+	imageCmd.AddCommand(imageTypesetCmd)
+	localImageFlags(imageTypesetCmd, "input", "source", "output")
+
+	imageTypesetCmd.Flags().StringVar(&CzFont, "font", "", "TTF/OTF font for rows without a font column")
+	imageTypesetCmd.Flags().BoolVar(&CzPalette, "palette", false, "rebuild the 4/8-bit palette after drawing (median cut), cz1 only")
+	imageTypesetCmd.Flags().BoolVar(&CzDither, "dither", false, "Floyd-Steinberg dithering when mapping to the 4/8-bit palette, cz1 only")
+	imageTypesetCmd.Flags().IntVarP(&CzJobs, "jobs", "j", 0, "compress blocks in parallel with this many goroutines, 0 keeps the sequential block layout")
+}
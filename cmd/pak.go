@@ -5,6 +5,7 @@ package cmd
 
 import (
 	"fmt"
+	"runtime"
 
 	"lucksystem/charset"
 	"lucksystem/pak"
@@ -25,7 +26,11 @@ var pakCmd = &cobra.Command{
 				return
 			}
 			fmt.Println("index,id,offset,size,name")
-			p := pak.LoadPak(PakSource, charset.Charset(Charset))
+			p, err := pak.Open(PakSource, charset.Charset(Charset))
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
 			for i, f := range p.Files {
 				fmt.Printf("%d,%d,%d,%d,%s\n", i, p.NameMap[f.Name], f.Offset, f.Length, f.Name)
 			}
@@ -38,8 +43,31 @@ var (
 	PakInput  string // 输入
 	PakOutput string // 输出
 	PakSource string // 原文件
+
+	PakJobs     int  // 并行处理的文件数
+	PakProgress bool // 输出进度
+	PakDedup    bool // 内容相同的文件共用数据
 )
 
+// setupPak 设置并行数与进度输出
+func setupPak(p *pak.Pak) *pak.Pak {
+	p.Jobs = PakJobs
+	p.Dedup = PakDedup
+	if PakProgress {
+		p.Progress = func(done, total int, e *pak.Entry) {
+			fmt.Printf("progress %d/%d %s\n", done, total, e.Name)
+		}
+	}
+	return p
+}
+
+// dedupReport 输出去重节省的空间
+func dedupReport(p *pak.Pak) {
+	if PakDedup {
+		fmt.Printf("Dedup: %s\n", p.DedupStats)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(pakCmd)
 
@@ -50,5 +78,9 @@ func init() {
 	pakCmd.PersistentFlags().StringVarP(&PakInput, "input", "i", "", "输入文件或文件夹")
 	pakCmd.PersistentFlags().StringVarP(&PakOutput, "output", "o", "", "输出文件或文件夹")
 
+	pakCmd.PersistentFlags().IntVarP(&PakJobs, "jobs", "j", runtime.NumCPU(), "number of entries extracted or written in parallel")
+	pakCmd.PersistentFlags().BoolVar(&PakProgress, "progress", false, "print a \"progress <done>/<total> <name>\" line after each entry")
+	pakCmd.PersistentFlags().BoolVar(&PakDedup, "dedup", false, "store byte-identical entries once and let them share an offset (forces a full rewrite)")
+
 	pakCmd.MarkFlagsRequiredTogether("output", "input")
 }
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"lucksystem/pak"
+
+	"github.com/spf13/cobra"
+)
+
+// pakBuildCmd represents the pak build command
+var pakBuildCmd = &cobra.Command{
+	Use:   "build",
+	Short: "Rebuild a PAK from a directory extracted with --manifest",
+	Long: `Rebuild a PAK from the files and manifest.json written by
+"pak extract --all <dir> --manifest".
+
+The header, name table, entry order, original offsets and any non-zero
+padding are restored from the manifest, so the output is byte-identical to
+the original PAK when no file changed. Edited files are written back in place
+when they still fit in their original blocks, otherwise appended at the end.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(PakInput) == 0 || len(PakOutput) == 0 {
+			return fmt.Errorf("required flag(s) \"input\" and \"output\" not set")
+		}
+		if !PakManifest {
+			return fmt.Errorf("pak build only supports --manifest, use pak create to build a new PAK from a directory")
+		}
+		file, dir := PakInput, PakInput
+		if fi, err := os.Stat(PakInput); err != nil {
+			return err
+		} else if fi.IsDir() {
+			file = filepath.Join(PakInput, pak.ManifestName)
+		} else {
+			dir = filepath.Dir(PakInput)
+		}
+		m, err := pak.LoadManifest(file)
+		if err != nil {
+			return err
+		}
+		out, err := os.Create(PakOutput)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+		if err = pak.BuildManifest(out, m, dir); err != nil {
+			out.Close()
+			os.Remove(PakOutput)
+			return err
+		}
+		fmt.Printf("Built %s: %d entries\n", PakOutput, len(m.Entries))
+		return nil
+	},
+}
+
+func init() {
+	pakCmd.AddCommand(pakBuildCmd)
+
+	pakBuildCmd.Flags().BoolVar(&PakManifest, "manifest", false, "rebuild from the manifest.json in the input directory (or the input manifest file)")
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"lucksystem/charset"
+	"lucksystem/pak"
+
+	"github.com/spf13/cobra"
+)
+
+// pakCreateCmd represents the pakCreate command
+var pakCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a new PAK from a directory",
+	Long: `Create a new LucaSystem PAK from every file of a directory, without a source PAK.
+
+Files are stored in name order (numeric order when every name is a number).
+Use this to ship add-on archives such as a separate font or CG pack.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(PakInput) == 0 || len(PakOutput) == 0 {
+			return fmt.Errorf("required flag(s) \"input\" and \"output\" not set")
+		}
+		p, err := pak.NewFromDir(PakInput, &pak.NewOptions{
+			Coding:    charset.Charset(Charset),
+			BlockSize: PakBlockSize,
+			IDStart:   PakIDStart,
+			Flags:     PakFlags,
+			Named:     !PakUnnamed,
+		})
+		if err != nil {
+			return err
+		}
+		out, err := os.Create(PakOutput)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+		if err = setupPak(p).Write(out); err != nil {
+			return err
+		}
+		fmt.Printf("Created %s: %d entries, block size %d\n", PakOutput, p.FileCount, p.BlockSize)
+		dedupReport(p)
+		return nil
+	},
+}
+
+var (
+	PakBlockSize uint32 // 新建pak的块大小
+	PakIDStart   uint32 // 新建pak的起始ID
+	PakFlags     uint32 // 新建pak的额外标志位
+	PakUnnamed   bool   // 新建pak不写入文件名表
+)
+
+func init() {
+	pakCmd.AddCommand(pakCreateCmd)
+
+	pakCreateCmd.Flags().Uint32Var(&PakBlockSize, "block_size", pak.DefaultBlockSize, "block size used to align entries")
+	pakCreateCmd.Flags().Uint32Var(&PakIDStart, "id_start", 0, "ID of the first entry")
+	pakCreateCmd.Flags().Uint32Var(&PakFlags, "flags", 0, "extra header flags (the named bit 512 is set automatically)")
+	pakCreateCmd.Flags().BoolVar(&PakUnnamed, "unnamed", false, "do not write a name table, entries are addressed by ID only")
+}
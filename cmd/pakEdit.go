@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"lucksystem/charset"
+	"lucksystem/pak"
+
+	"github.com/spf13/cobra"
+)
+
+// pakAddCmd represents the pak add command
+var pakAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Add a new entry to a PAK",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(PakSource) == 0 || len(PakInput) == 0 || len(PakOutput) == 0 {
+			return fmt.Errorf("required flag(s) \"source\", \"input\" and \"output\" not set")
+		}
+		p, err := pak.Open(PakSource, charset.Charset(Charset))
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(PakInput)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err = p.Add(PakName, f); err != nil {
+			return err
+		}
+		return writePak(p, PakOutput)
+	},
+}
+
+// pakRemoveCmd represents the pak rm command
+var pakRemoveCmd = &cobra.Command{
+	Use:   "rm",
+	Short: "Remove an entry from a PAK",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(PakSource) == 0 || len(PakOutput) == 0 {
+			return fmt.Errorf("required flag(s) \"source\" and \"output\" not set")
+		}
+		p, err := pak.Open(PakSource, charset.Charset(Charset))
+		if err != nil {
+			return err
+		}
+		if err = p.Remove(PakName); err != nil {
+			return err
+		}
+		return writePak(p, PakOutput)
+	},
+}
+
+// pakRenameCmd represents the pak mv command
+var pakRenameCmd = &cobra.Command{
+	Use:   "mv",
+	Short: "Rename an entry of a PAK",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(PakSource) == 0 || len(PakOutput) == 0 {
+			return fmt.Errorf("required flag(s) \"source\" and \"output\" not set")
+		}
+		p, err := pak.Open(PakSource, charset.Charset(Charset))
+		if err != nil {
+			return err
+		}
+		if err = p.Rename(PakName, PakNewName); err != nil {
+			return err
+		}
+		return writePak(p, PakOutput)
+	},
+}
+
+// writePak 写入pak到文件，失败时删除不完整的输出
+func writePak(p *pak.Pak, file string) error {
+	out, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	err = setupPak(p).Write(out)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file)
+		return err
+	}
+	dedupReport(p)
+	return nil
+}
+
+// localPakFlags 为不需要input的子命令定义本地的source与output
+//
+//	Description 本地flag覆盖pakCmd的同名persistent flag，
+//	  使pakCmd中output与input必须同时指定的限制不作用于该子命令
+//	Param cmd *cobra.Command
+func localPakFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&PakSource, "source", "s", "", "原Pak文件名")
+	cmd.Flags().StringVarP(&PakOutput, "output", "o", "", "输出文件或文件夹")
+}
+
+var (
+	PakNewName string
+)
+
+func init() {
+	pakCmd.AddCommand(pakAddCmd)
+	pakCmd.AddCommand(pakRemoveCmd)
+	pakCmd.AddCommand(pakRenameCmd)
+
+	// source为要修改的pak，input为add添加的文件，均为pakCmd的persistent flag，在RunE中检查
+	pakAddCmd.Flags().StringVarP(&PakName, "name", "n", "", "name of the new entry")
+
+	localPakFlags(pakRemoveCmd)
+	pakRemoveCmd.Flags().StringVarP(&PakName, "name", "n", "", "name of the entry to remove")
+	pakRemoveCmd.MarkFlagRequired("name")
+
+	localPakFlags(pakRenameCmd)
+	pakRenameCmd.Flags().StringVarP(&PakName, "name", "n", "", "current entry name")
+	pakRenameCmd.Flags().StringVar(&PakNewName, "to", "", "new entry name")
+	pakRenameCmd.MarkFlagRequired("name")
+	pakRenameCmd.MarkFlagRequired("to")
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"lucksystem/charset"
+	"lucksystem/pak"
+)
+
+// writeEditPak 创建包含a、b的测试pak
+func writeEditPak(t *testing.T, file string) {
+	t.Helper()
+	p := pak.New(&pak.NewOptions{BlockSize: 16, Named: true})
+	for _, name := range []string{"a", "b"} {
+		if err := p.Add(name, strings.NewReader(name+name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	if err = p.Write(out); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// entryNames pak中的文件名
+func entryNames(t *testing.T, file string) string {
+	t.Helper()
+	p, err := pak.Open(file, charset.UTF_8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range p.Files {
+		names = append(names, e.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestPakRemoveRename(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "A.PAK")
+	writeEditPak(t, src)
+
+	removed := filepath.Join(dir, "B.PAK")
+	rootCmd.SetArgs([]string{"pak", "rm", "-s", src, "-n", "a", "-o", removed})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("pak rm: %v", err)
+	}
+	if names := entryNames(t, removed); names != "b" {
+		t.Errorf("after rm: %s", names)
+	}
+
+	renamed := filepath.Join(dir, "C.PAK")
+	rootCmd.SetArgs([]string{"pak", "mv", "-s", src, "-n", "a", "--to", "c", "-o", renamed})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("pak mv: %v", err)
+	}
+	if names := entryNames(t, renamed); names != "c,b" {
+		t.Errorf("after mv: %s", names)
+	}
+}
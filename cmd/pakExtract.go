@@ -1,6 +1,5 @@
 /*
 Copyright © 2022 NAME HERE <EMAIL ADDRESS>
-
 */
 package cmd
 
@@ -9,8 +8,13 @@ import (
 	"github.com/golang/glog"
 	"github.com/spf13/cobra"
 	"lucksystem/charset"
+	"lucksystem/czimage"
+	"lucksystem/movie"
 	"lucksystem/pak"
+	"lucksystem/voice"
 	"os"
+	"strconv"
+	"strings"
 )
 
 // pakExtractCmd represents the pakExtract command
@@ -21,13 +25,26 @@ var pakExtractCmd = &cobra.Command{
 无具体文件头，确定是LucaSystem引擎的游戏，文件名为大写的***.PAK`,
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("pakExtract called")
-		p := pak.LoadPak(PakInput, charset.Charset(Charset))
+		p, err := pak.Open(PakInput, charset.Charset(Charset))
+		if err != nil {
+			glog.Fatalln(err)
+		}
+		setupPak(p)
 		out, err := os.Create(PakOutput)
 		if err != nil {
 			glog.Fatalln(err)
 		}
 		defer out.Close()
-		if len(PakAll) > 0 {
+		if len(PakAll) > 0 && (PakDetect || PakConvert || PakManifest) {
+			opts := &pak.ExtractOptions{Detect: PakDetect, Layout: PakManifest}
+			if PakConvert {
+				opts.Convert = convertPakEntry
+			}
+			_, err := p.Extract(out, PakAll, opts)
+			if err != nil {
+				glog.Fatalln(err)
+			}
+		} else if len(PakAll) > 0 {
 			err := p.Export(out, "all", PakAll)
 			if err != nil {
 				glog.Fatalln(err)
@@ -54,11 +71,66 @@ var pakExtractCmd = &cobra.Command{
 	},
 }
 
+// convertPakEntry 将子文件转换为通用格式，写入file同目录下
+//
+//	Description CZ→PNG，MVT→WebM，OggPak→ogg
+//	Param t pak.FileType
+//	Param data []byte
+//	Param file string 原始数据文件
+//	Return files []string 转换后的文件
+//	Return err error
+func convertPakEntry(t pak.FileType, data []byte, file string) (files []string, err error) {
+	base := strings.TrimSuffix(file, t.Ext())
+	switch t {
+	case pak.TypeCZ0, pak.TypeCZ1, pak.TypeCZ2, pak.TypeCZ3, pak.TypeCZ4:
+		cz, err := czimage.ReadCzImage(data)
+		if err != nil {
+			return nil, fmt.Errorf("convert %s: %v", t, err)
+		}
+		out, err := os.Create(base + ".png")
+		if err != nil {
+			return nil, err
+		}
+		defer out.Close()
+		if err = cz.Export(out); err != nil {
+			return nil, err
+		}
+		return []string{out.Name()}, nil
+	case pak.TypeMVT:
+		payload, err := movie.WebMPayload(data)
+		if err != nil {
+			return nil, err
+		}
+		if err = os.WriteFile(base+".webm", payload, 0666); err != nil {
+			return nil, err
+		}
+		return []string{base + ".webm"}, nil
+	case pak.TypeOggPak:
+		ogg, err := voice.LoadOggPak(0, data)
+		if err != nil {
+			return nil, err
+		}
+		for j, f := range ogg.Files {
+			name := base + "_" + strconv.Itoa(j) + ".ogg"
+			if err = os.WriteFile(name, f.Data, 0666); err != nil {
+				return files, err
+			}
+			files = append(files, name)
+		}
+		return files, nil
+	}
+	return nil, nil
+}
+
 var (
 	PakAll   string
 	PakIndex int
 	PakId    int
 	PakName  string
+
+	PakDetect   bool // 根据内容添加扩展名
+	PakConvert  bool // 转换为通用格式
+	PakManifest bool // 清单中记录完整结构，用于逐字节重建
 )
 
 func init() {
@@ -69,5 +141,10 @@ func init() {
 	pakExtractCmd.Flags().IntVarP(&PakId, "id", "d", -1, "提取指定ID文件")
 	pakExtractCmd.Flags().StringVarP(&PakName, "name", "n", "", "提取指定文件名文件")
 
+	pakExtractCmd.Flags().BoolVar(&PakDetect, "detect", false, "with --all: detect CZ/MVT/OggPak/script entries, append extensions and write "+pak.ManifestName)
+	pakExtractCmd.Flags().BoolVar(&PakConvert, "convert", false, "with --all: also convert CZ to PNG, MVT to WebM and OggPak to .ogg (implies --detect)")
+
+	pakExtractCmd.Flags().BoolVar(&PakManifest, "manifest", false, "with --all: record the full layout in "+pak.ManifestName+" for pak build --manifest")
+
 	pakExtractCmd.MarkFlagsMutuallyExclusive("all", "index", "id", "name")
 }
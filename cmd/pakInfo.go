@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"lucksystem/charset"
+	"lucksystem/pak"
+
+	"github.com/spf13/cobra"
+)
+
+// pakInfoCmd represents the pak info command
+var pakInfoCmd = &cobra.Command{
+	Use:     "info [pak]",
+	Aliases: []string{"list"},
+	Short:   "Show the header and entry table of a PAK",
+	Long: `Show the header fields (including the unknown Unk2-Unk5 and Flags), the
+offset table position and every entry with its ID, name, offset, length and
+detected content type.
+
+The PAK is given with --source, like the other pak commands that read an
+existing archive, or as the only argument.
+
+Use --json for a machine-readable dump, e.g. to diff archive layouts between
+game versions in build scripts or CI.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file := PakSource
+		if len(file) == 0 && len(args) > 0 {
+			file = args[0]
+		}
+		if len(file) == 0 {
+			return fmt.Errorf("required flag(s) \"source\" not set")
+		}
+		p, err := pak.Open(file, charset.Charset(Charset))
+		if err != nil {
+			return err
+		}
+		info, err := p.GetInfo()
+		if err != nil {
+			return err
+		}
+		if PakJson {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(info)
+		}
+		h := info.Header
+		fmt.Printf("file: %s\n", info.FileName)
+		fmt.Printf("header_length: %d\nfile_count: %d\nid_start: %d\nblock_size: %d\n",
+			h.HeaderLength, h.FileCount, h.IDStart, h.BlockSize)
+		fmt.Printf("unk2: %d\nunk3: %d\nunk4: %d\nunk5: %d\nflags: %d (named: %v)\n",
+			h.Unk2, h.Unk3, h.Unk4, h.Unk5, h.Flags, info.Named)
+		fmt.Printf("offset_pos: %d\ndata_pos: %d\n", info.OffsetPos, info.DataPos)
+		fmt.Println("index,id,offset,size,type,name")
+		for _, e := range info.Entries {
+			fmt.Printf("%d,%d,%d,%d,%s,%s\n", e.Index, e.ID, e.Offset, e.Length, e.Type, e.Name)
+		}
+		return nil
+	},
+}
+
+var (
+	PakJson bool // json格式输出
+)
+
+func init() {
+	pakCmd.AddCommand(pakInfoCmd)
+
+	pakInfoCmd.Flags().BoolVar(&PakJson, "json", false, "print the archive layout as JSON")
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+
+	"lucksystem/charset"
+	"lucksystem/pak"
+
+	"github.com/spf13/cobra"
+)
+
+// pakMergeCmd represents the pak merge command
+var pakMergeCmd = &cobra.Command{
+	Use:   "merge [more patch PAKs...]",
+	Short: "Merge patch PAKs over a retail PAK",
+	Long: `Flatten a stack of PAKs into a single archive.
+
+The source PAK (-s) is the lowest priority layer, the input PAK (-i) goes on
+top of it, and any extra PAK given as argument goes on top of that, in order.
+For each entry name (or ID for PAKs without a name table) the highest layer
+wins; entries missing from the source are appended.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(PakSource) == 0 {
+			return fmt.Errorf("required flag(s) \"source\" not set")
+		}
+		coding := charset.Charset(Charset)
+		o := &pak.Overlay{}
+		for _, file := range append([]string{PakSource, PakInput}, args...) {
+			p, err := pak.Open(file, coding)
+			if err != nil {
+				return err
+			}
+			o.Push(p)
+		}
+		p, err := o.Flatten()
+		if err != nil {
+			return err
+		}
+		if err = writePak(p, PakOutput); err != nil {
+			return err
+		}
+		fmt.Printf("Merged %d PAK(s) into %s: %d entries\n", len(o.Layers), PakOutput, p.FileCount)
+		return nil
+	},
+}
+
+func init() {
+	pakCmd.AddCommand(pakMergeCmd)
+}
@@ -5,6 +5,7 @@ Copyright © 2022 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"github.com/golang/glog"
 	"lucksystem/charset"
@@ -24,7 +25,11 @@ var pakReplaceCmd = &cobra.Command{
 			fmt.Println("Error: required flag(s) \"source\" not set")
 			return
 		}
-		p := pak.LoadPak(PakSource, charset.Charset(Charset))
+		p, err := pak.Open(PakSource, charset.Charset(Charset))
+		if err != nil {
+			glog.Fatalln(err)
+		}
+		setupPak(p)
 		out, err := os.Create(PakOutput)
 		if err != nil {
 			glog.Fatalln(err)
@@ -34,7 +39,7 @@ var pakReplaceCmd = &cobra.Command{
 			glog.Fatalln(err)
 		} else if fi.IsDir() {
 			err := p.Import(nil, "dir", PakInput)
-			if err != nil {
+			if err != nil && !importReport(err) {
 				glog.Fatalln(err)
 			}
 		} else {
@@ -45,7 +50,7 @@ var pakReplaceCmd = &cobra.Command{
 			defer f.Close()
 			if PakList {
 				err := p.Import(f, "list", nil)
-				if err != nil {
+				if err != nil && !importReport(err) {
 					glog.Fatalln(err)
 				}
 			} else if PakId >= 0 {
@@ -65,9 +70,20 @@ var pakReplaceCmd = &cobra.Command{
 		if err != nil {
 			glog.Fatalln(err)
 		}
+		dedupReport(p)
 	},
 }
 
+// importReport 输出部分导入失败的报告，其余文件继续写入
+func importReport(err error) bool {
+	var report *pak.ImportError
+	if !errors.As(err, &report) {
+		return false
+	}
+	glog.Warningln(report)
+	return true
+}
+
 var (
 	PakList bool
 )
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"lucksystem/charset"
+	"lucksystem/pak"
+
+	"github.com/spf13/cobra"
+)
+
+// pakVerifyCmd represents the pak verify command
+var pakVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check a PAK for overlaps, gaps, bad alignment and truncated tails",
+	Long: `Check the structure of a PAK: block alignment of every entry, overlapping
+ranges, offsets past the end of file, unused gaps, name table consistency with
+the named flag (512) and the trailing block padding.
+
+Exits with a non-zero code when an error is found (or any warning with --strict),
+so it can be used in CI after rebuilding archives.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file := PakSource
+		if len(file) == 0 && len(args) > 0 {
+			file = args[0]
+		}
+		if len(file) == 0 {
+			return fmt.Errorf("required flag(s) \"source\" not set")
+		}
+		p, err := pak.Open(file, charset.Charset(Charset))
+		if err != nil {
+			return err
+		}
+		problems := p.Verify()
+		if PakJson {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err = enc.Encode(problems); err != nil {
+				return err
+			}
+		} else {
+			for _, pr := range problems {
+				fmt.Println(pr)
+			}
+		}
+		failed := pak.HasErrors(problems) || (PakStrict && len(problems) > 0)
+		if failed {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("%s: %d problem(s) found", file, len(problems))
+		}
+		if !PakJson {
+			fmt.Printf("%s: OK (%d entries, %d warning(s))\n", file, p.FileCount, len(problems))
+		}
+		return nil
+	},
+}
+
+var (
+	PakStrict bool // 警告也视为失败
+)
+
+func init() {
+	pakCmd.AddCommand(pakVerifyCmd)
+
+	pakVerifyCmd.Flags().BoolVar(&PakJson, "json", false, "print problems as JSON")
+	pakVerifyCmd.Flags().BoolVar(&PakStrict, "strict", false, "fail on warnings too")
+}
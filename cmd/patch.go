@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+
+	"lucksystem/charset"
+
+	"github.com/spf13/cobra"
+)
+
+// patchCmd represents the patch command
+var patchCmd = &cobra.Command{
+	Use:   "patch",
+	Short: "Binary PAK patches (.lpk)",
+	Long: `Binary PAK patches (.lpk)
+A patch records entry-level differences (replaced, added and removed entries)
+between an original PAK and a modified PAK, plus hashes of the original file.
+It only contains the new data, so translations can be distributed without
+redistributing game data.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("patch called")
+	},
+}
+
+var (
+	PatchOrig    string // 原pak
+	PatchNew     string // 修改后的pak
+	PatchFile    string // lpk补丁
+	PatchOutput  string // 输出
+	PatchCharset string // 编码
+)
+
+func init() {
+	rootCmd.AddCommand(patchCmd)
+
+	patchCmd.PersistentFlags().StringVar(&PatchOrig, "orig", "", "original PAK file")
+	patchCmd.PersistentFlags().StringVarP(&PatchOutput, "output", "o", "", "output file")
+	patchCmd.PersistentFlags().StringVarP(&PatchCharset, "charset", "c", string(charset.UTF_8), "entry name charset")
+
+	patchCmd.MarkPersistentFlagRequired("orig")
+	patchCmd.MarkPersistentFlagRequired("output")
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+
+	"lucksystem/charset"
+	"lucksystem/pak"
+	"lucksystem/patch"
+
+	"github.com/spf13/cobra"
+)
+
+// patchApplyCmd represents the patch apply command
+var patchApplyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Apply a patch to the user's own copy of the original PAK",
+	Long: `Apply a patch to the user's own copy of the original PAK.
+The original PAK and every replaced or removed entry are checked against the
+hashes stored in the patch; nothing is written when they don't match.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pt, err := patch.LoadFile(PatchFile)
+		if err != nil {
+			return err
+		}
+		orig, err := pak.Open(PatchOrig, charset.Charset(PatchCharset))
+		if err != nil {
+			return err
+		}
+		if err = pt.Apply(orig); err != nil {
+			return err
+		}
+		if err = writePak(orig, PatchOutput); err != nil {
+			return err
+		}
+		fmt.Printf("Applied %s: %d change(s) -> %s\n", PatchFile, pt.Count, PatchOutput)
+		return nil
+	},
+}
+
+func init() {
+	patchCmd.AddCommand(patchApplyCmd)
+
+	patchApplyCmd.Flags().StringVarP(&PatchFile, "patch", "p", "", "patch file (.lpk)")
+	patchApplyCmd.MarkFlagRequired("patch")
+}
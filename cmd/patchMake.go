@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"lucksystem/charset"
+	"lucksystem/pak"
+	"lucksystem/patch"
+
+	"github.com/spf13/cobra"
+)
+
+// patchMakeCmd represents the patch make command
+var patchMakeCmd = &cobra.Command{
+	Use:   "make",
+	Short: "Create a patch from an original and a modified PAK",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		orig, err := pak.Open(PatchOrig, charset.Charset(PatchCharset))
+		if err != nil {
+			return err
+		}
+		modified, err := pak.Open(PatchNew, charset.Charset(PatchCharset))
+		if err != nil {
+			return err
+		}
+		pt, err := patch.Make(orig, modified)
+		if err != nil {
+			return err
+		}
+		out, err := os.Create(PatchOutput)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+		if err = pt.Write(out); err != nil {
+			return err
+		}
+		for _, op := range pt.Ops {
+			fmt.Println(op)
+		}
+		fmt.Printf("Created %s: %d change(s)\n", PatchOutput, pt.Count)
+		return nil
+	},
+}
+
+func init() {
+	patchCmd.AddCommand(patchMakeCmd)
+
+	patchMakeCmd.Flags().StringVar(&PatchNew, "new", "", "modified PAK file")
+	patchMakeCmd.MarkFlagRequired("new")
+}
@@ -0,0 +1,201 @@
+package czimage
+
+import (
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// SheetEntry 索引图中的一项
+type SheetEntry struct {
+	Name   string          `json:"name"`
+	ID     int             `json:"id"`
+	Offset uint32          `json:"offset"` // 在pak中的位置
+	Length uint32          `json:"length"`
+	Meta   *Metadata       `json:"meta,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	Page   int             `json:"page"` // 所在索引图
+	Cell   image.Rectangle `json:"cell"` // 在索引图中的位置
+
+	Thumb *image.NRGBA `json:"-"` // 缩略图，解码失败时为nil
+}
+
+// NewSheetEntry 解码cz并生成缩略图
+//
+//	Description 只保留缩略图，可依次处理大量CG。解码失败或panic记录在Error中
+//	Param name string
+//	Param id int
+//	Param data []byte cz文件数据
+//	Param size int 缩略图最大宽高
+//	Return *SheetEntry
+func NewSheetEntry(name string, id int, data []byte, size int) (entry *SheetEntry) {
+	entry = &SheetEntry{Name: name, ID: id}
+	defer func() {
+		if e := recover(); e != nil {
+			entry.Error = fmt.Sprint(e)
+		}
+	}()
+	cz, err := ReadCzImage(data)
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+	entry.Meta = GetMetadata(cz)
+	img, err := cz.GetImage()
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+	entry.Thumb = Thumbnail(ImageToNRGBA(img), size)
+	return entry
+}
+
+// SheetOptions 索引图布局
+type SheetOptions struct {
+	Columns  int // 每行缩略图数，默认8
+	Rows     int // 每页行数，默认6
+	CellSize int // 缩略图最大宽高，默认160
+}
+
+// sheetLabelHeight 缩略图下方文字的高度
+const sheetLabelHeight = 16
+
+func (opt *SheetOptions) setDefaults() {
+	if opt.Columns <= 0 {
+		opt.Columns = 8
+	}
+	if opt.Rows <= 0 {
+		opt.Rows = 6
+	}
+	if opt.CellSize <= 0 {
+		opt.CellSize = 160
+	}
+}
+
+// ContactSheets 将缩略图排列为分页的索引图
+//
+//	Description 每格为棋盘格背景上居中的缩略图，下方为ID与名称。
+//	  设置各项的Page与Cell，供 WriteSheetIndex 使用
+//	Param entries []*SheetEntry
+//	Param opt SheetOptions
+//	Return []*image.NRGBA
+func ContactSheets(entries []*SheetEntry, opt SheetOptions) []*image.NRGBA {
+	opt.setDefaults()
+	cellW, cellH := opt.CellSize+4, opt.CellSize+4+sheetLabelHeight
+	perPage := opt.Columns * opt.Rows
+	var sheets []*image.NRGBA
+	for i, e := range entries {
+		n := i % perPage
+		if n == 0 {
+			rows := (len(entries) - i + opt.Columns - 1) / opt.Columns
+			if rows > opt.Rows {
+				rows = opt.Rows
+			}
+			sheet := image.NewNRGBA(image.Rect(0, 0, opt.Columns*cellW, rows*cellH))
+			draw.Draw(sheet, sheet.Rect, image.White, image.Point{}, draw.Src)
+			sheets = append(sheets, sheet)
+		}
+		e.Page = len(sheets) - 1
+		e.Cell = image.Rect(0, 0, cellW, cellH).Add(image.Pt(n%opt.Columns*cellW, n/opt.Columns*cellH))
+		drawSheetCell(sheets[e.Page], e, opt.CellSize)
+	}
+	return sheets
+}
+
+// drawSheetCell 绘制一格
+func drawSheetCell(sheet *image.NRGBA, e *SheetEntry, size int) {
+	area := image.Rect(0, 0, size, size).Add(e.Cell.Min.Add(image.Pt(2, 2)))
+	if e.Thumb == nil {
+		draw.Draw(sheet, area, image.NewUniform(color.NRGBA{R: 0xE0, G: 0x80, B: 0x80, A: 0xFF}), image.Point{}, draw.Src)
+	} else {
+		// 棋盘格显示透明部分
+		for y := area.Min.Y; y < area.Max.Y; y++ {
+			for x := area.Min.X; x < area.Max.X; x++ {
+				if (x/8+y/8)%2 == 0 {
+					sheet.SetNRGBA(x, y, color.NRGBA{R: 0xDD, G: 0xDD, B: 0xDD, A: 0xFF})
+				}
+			}
+		}
+		b := e.Thumb.Rect
+		at := area.Min.Add(image.Pt((size-b.Dx())/2, (size-b.Dy())/2))
+		draw.Draw(sheet, image.Rectangle{Min: at, Max: at.Add(b.Size())}, e.Thumb, b.Min, draw.Over)
+	}
+
+	label := []rune(fmt.Sprintf("%d %s", e.ID, e.Name))
+	d := &font.Drawer{Dst: sheet, Src: image.Black, Face: basicfont.Face7x13}
+	for len(label) > 0 && d.MeasureString(string(label)).Ceil() > e.Cell.Dx()-4 {
+		label = label[:len(label)-1]
+	}
+	d.Dot = fixed.P(e.Cell.Min.X+2, e.Cell.Max.Y-4)
+	d.DrawString(string(label))
+}
+
+// WriteSheetIndex 生成索引图的html索引
+//
+//	Description 列出各项的名称、ID、类型、宽高与位置，
+//	  索引图上的每格链接到表格中对应的行
+//	Param w io.Writer
+//	Param title string
+//	Param entries []*SheetEntry 已由 ContactSheets 排列
+//	Param pages []string 各页索引图的文件名
+//	Return error
+func WriteSheetIndex(w io.Writer, title string, entries []*SheetEntry, pages []string) error {
+	type page struct {
+		File    string
+		Entries []*SheetEntry
+	}
+	index := struct {
+		Title string
+		Pages []*page
+	}{Title: title}
+	for _, file := range pages {
+		index.Pages = append(index.Pages, &page{File: file})
+	}
+	for _, e := range entries {
+		if e.Page < 0 || e.Page >= len(index.Pages) {
+			return fmt.Errorf("%s: page %d out of range", e.Name, e.Page)
+		}
+		index.Pages[e.Page].Entries = append(index.Pages[e.Page].Entries, e)
+	}
+	return sheetIndexTemplate.Execute(w, index)
+}
+
+var sheetIndexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 1em 2em; }
+table { border-collapse: collapse; font-size: 0.9em; margin-bottom: 2em; }
+td, th { padding: 0 0.6em; text-align: left; }
+tr:target { background: #ff8; }
+.error { color: #c00; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{range $i, $p := .Pages}}
+<h2 id="page{{$i}}">Page {{$i}}</h2>
+<img src="{{$p.File}}" usemap="#map{{$i}}">
+<map name="map{{$i}}">{{range $p.Entries}}
+<area shape="rect" coords="{{.Cell.Min.X}},{{.Cell.Min.Y}},{{.Cell.Max.X}},{{.Cell.Max.Y}}" href="#entry{{.ID}}" title="{{.ID}} {{.Name}}">{{end}}
+</map>
+<table>
+<tr><th>ID</th><th>Name</th><th>Offset</th><th>Length</th><th>Type</th><th>Size</th><th>Position</th><th>Colorbits</th><th>Error</th></tr>
+{{range $p.Entries}}<tr id="entry{{.ID}}"><td>{{.ID}}</td><td>{{.Name}}</td><td>{{.Offset}}</td><td>{{.Length}}</td>
+{{with .Meta}}<td>{{.Type}}</td><td>{{.Width}}x{{.Height}}</td><td>{{with .Position}}{{.X}},{{.Y}}{{end}}</td><td>{{.Colorbits}}</td>{{else}}<td colspan="4"></td>{{end}}
+<td class="error">{{.Error}}</td></tr>
+{{end}}
+</table>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,62 @@
+package czimage
+
+import (
+	"bytes"
+	"image"
+	"strings"
+	"testing"
+)
+
+func TestContactSheets(t *testing.T) {
+	var entries []*SheetEntry
+	for i := 0; i < 5; i++ {
+		data := encodeCz(t, testImage(40+i*10, 30, false), EncodeOptions{Type: "cz3", X: uint16(i)})
+		entries = append(entries, NewSheetEntry("cg"+string(rune('a'+i)), 100+i, data, 32))
+	}
+	entries = append(entries, NewSheetEntry("broken", 200, []byte("CZ3\x00truncated header"), 32))
+	for _, e := range entries[:5] {
+		if len(e.Error) != 0 || e.Thumb == nil || e.Thumb.Rect.Dx() > 32 || e.Thumb.Rect.Dy() > 32 {
+			t.Fatalf("%s: %+v", e.Name, e)
+		}
+	}
+	if e := entries[5]; len(e.Error) == 0 || e.Thumb != nil {
+		t.Errorf("broken entry: %+v", e)
+	}
+
+	sheets := ContactSheets(entries, SheetOptions{Columns: 2, Rows: 2, CellSize: 32})
+	if len(sheets) != 2 {
+		t.Fatalf("%d sheets, want 2", len(sheets))
+	}
+	cellW, cellH := 36, 36+sheetLabelHeight
+	if sheets[0].Rect != image.Rect(0, 0, 2*cellW, 2*cellH) || sheets[1].Rect != image.Rect(0, 0, 2*cellW, cellH) {
+		t.Errorf("sheet sizes %v %v", sheets[0].Rect, sheets[1].Rect)
+	}
+	if e := entries[3]; e.Page != 0 || e.Cell != image.Rect(cellW, cellH, 2*cellW, 2*cellH) {
+		t.Errorf("entry 3 at page %d cell %v", e.Page, e.Cell)
+	}
+	if e := entries[5]; e.Page != 1 || e.Cell.Min != image.Pt(cellW, 0) {
+		t.Errorf("entry 5 at page %d cell %v", e.Page, e.Cell)
+	}
+
+	entries[4].Offset, entries[4].Length = 20480, 1234
+	buf := &bytes.Buffer{}
+	if err := WriteSheetIndex(buf, "SYSCG.PAK", entries, []string{"sheet_000.png", "sheet_001.png"}); err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+	for _, s := range []string{"sheet_001.png", `id="entry104"`, "cz3", "60x30", "<td>3,0</td>", "<td>20480</td><td>1234</td>", "broken"} {
+		if !strings.Contains(html, s) {
+			t.Errorf("index does not contain %q", s)
+		}
+	}
+	// 每行的列数与表头相同
+	for _, row := range strings.Split(html, "<tr")[1:] {
+		n := strings.Count(row, "<th>") + strings.Count(row, "<td") + 3*strings.Count(row, `colspan="4"`)
+		if n != 9 {
+			t.Errorf("row with %d cells: %s", n, row)
+		}
+	}
+	if err := WriteSheetIndex(buf, "x", entries, nil); err == nil {
+		t.Error("expected an error for missing pages")
+	}
+}
@@ -2,6 +2,7 @@ package czimage
 
 import (
 	"encoding/binary"
+	"fmt"
 	"image"
 	"io"
 	"os"
@@ -30,7 +31,27 @@ type CzData struct {
 	OutputInfo *CzOutputInfo // Load()
 	Image      image.Image   // Export()
 	PngImage   image.Image   // Import()
+	BlockSize  int           // Import() 压缩分块大小，0则沿用原文件的分块大小
+	Jobs       int           // Import() 并行压缩的数量，0为不并行
+}
 
+// blockSize 压缩时使用的分块大小，0为默认值
+func (d *CzData) blockSize() int {
+	if d.BlockSize > 0 {
+		return d.BlockSize
+	}
+	if d.OutputInfo != nil && len(d.OutputInfo.BlockInfo) != 0 {
+		return int(d.OutputInfo.BlockInfo[0].CompressedSize)
+	}
+	return 0
+}
+
+// compress 使用 Compress 或 CompressParallel 压缩数据
+func (d *CzData) compress(data []byte) ([]byte, *CzOutputInfo) {
+	if d.Jobs > 0 {
+		return CompressParallel(data, d.blockSize(), d.Jobs)
+	}
+	return Compress(data, d.blockSize())
 }
 
 // CzBlockInfo
@@ -52,61 +73,112 @@ type CzOutputInfo struct {
 	BlockInfo           []CzBlockInfo `struct:"size=FileCount"`
 }
 
+// 解码时的大小限制，超过则视为损坏的文件
+const (
+	maxImagePixels = 1 << 26            // 宽*高，8192x8192
+	maxRawSize     = maxImagePixels * 4 // 解压后数据大小
+)
+
+// checkColorbits 颜色位数是否为解码器支持的值
+func checkColorbits(colorbits uint16, supported ...uint16) error {
+	for _, c := range supported {
+		if colorbits == c {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported colorbits %d (want %v)", colorbits, supported)
+}
+
+// checkRawSize 分块信息中的解压后大小是否足够宽*高的像素，在解压并分配图像之前检查
+func checkRawSize(info *CzOutputInfo, need int) error {
+	if info.TotalRawSize < need {
+		return fmt.Errorf("%d bytes of raw data in the block table, image needs %d", info.TotalRawSize, need)
+	}
+	return nil
+}
+
 type CzImage interface {
-	Load(header CzHeader, data []byte)
-	GetImage() image.Image
+	Load(header CzHeader, data []byte) error
+	GetImage() (image.Image, error)
 	Export(w io.Writer) error
 	Import(r io.Reader, fillSize bool) error
 	Write(w io.Writer) error
 }
 
+// LoadCzImageFile 读取cz文件
+//
+//	Description 同 LoadCzImage，文件无法读取时同样记录警告并返回nil
+//	Param file string
+//	Return CzImage
 func LoadCzImageFile(file string) CzImage {
 	data, err := os.ReadFile(file)
 	if err != nil {
-		glog.Fatalln(err)
+		glog.Warningf("%v, skipping\n", err)
+		return nil
 	}
 	return LoadCzImage(data)
 }
+
+// LoadCzImage 读取cz文件
+//
+//	Description 同 ReadCzImage，文件无效时记录警告并返回nil
+//	Param data []byte
+//	Return CzImage
 func LoadCzImage(data []byte) CzImage {
+	cz, err := ReadCzImage(data)
+	if err != nil {
+		glog.Warningf("%v, skipping\n", err)
+		return nil
+	}
+	return cz
+}
+
+// ReadCzImage 读取cz文件
+//
+//	Description 检查文件头、扩展头、调色板与分块信息，不解压图像数据
+//	Param data []byte
+//	Return CzImage
+//	Return error
+func ReadCzImage(data []byte) (CzImage, error) {
 	// Safety check: file must be at least 15 bytes for a valid CZ header
 	if len(data) < 15 {
-		glog.Warningf("File too small for CZ header (%d bytes), skipping\n", len(data))
-		return nil
+		return nil, fmt.Errorf("file too small for CZ header (%d bytes)", len(data))
 	}
 
 	// Check magic bytes before unpacking
 	if data[0] != 'C' || data[1] != 'Z' {
-		glog.Warningf("Not a CZ file (magic: 0x%02x%02x), skipping\n", data[0], data[1])
-		return nil
+		return nil, fmt.Errorf("not a CZ file (magic: 0x%02x%02x)", data[0], data[1])
 	}
 
 	header := CzHeader{}
 	err := restruct.Unpack(data[:15], binary.LittleEndian, &header)
 	if err != nil {
-		glog.Fatalln(err)
+		return nil, err
 	}
 	glog.V(6).Infoln("cz header", header)
+	if header.HeaderLength < 15 || int64(header.HeaderLength) > int64(len(data)) {
+		return nil, fmt.Errorf("header length %d out of range (file is %d bytes)", header.HeaderLength, len(data))
+	}
+	if pixels := int(header.Width) * int(header.Heigth); pixels > maxImagePixels {
+		return nil, fmt.Errorf("image size %dx%d exceeds %d pixels", header.Width, header.Heigth, maxImagePixels)
+	}
 	var cz CzImage
 	switch string(header.Magic[:3]) {
 	case "CZ0":
 		cz = new(Cz0Image)
-		cz.Load(header, data)
 	case "CZ1":
 		cz = new(Cz1Image)
-		cz.Load(header, data)
 	case "CZ2":
 		cz = new(Cz2Image)
-		cz.Load(header, data)
 	case "CZ3":
 		cz = new(Cz3Image)
-		cz.Load(header, data)
 	case "CZ4":
 		cz = new(Cz4Image)
-		cz.Load(header, data)
 	default:
-		glog.Warningf("Unknown CZ image type: %s, skipping\n", string(header.Magic[:3]))
-		return nil
+		return nil, fmt.Errorf("unknown CZ image type: %q", header.Magic[:3])
 	}
-
-	return cz
+	if err = cz.Load(header, data); err != nil {
+		return nil, fmt.Errorf("%s: %w", header.Magic[:3], err)
+	}
+	return cz, nil
 }
@@ -2,6 +2,7 @@ package czimage
 
 import (
 	"encoding/binary"
+	"fmt"
 	"github.com/go-restruct/restruct"
 	"github.com/golang/glog"
 	"image"
@@ -44,12 +45,19 @@ type Cz0Image struct {
 //	Receiver cz *Cz0Image
 //	Param header CzHeader
 //	Param data []byte
-func (cz *Cz0Image) Load(header CzHeader, data []byte) {
+//	Return error
+func (cz *Cz0Image) Load(header CzHeader, data []byte) error {
 	cz.CzHeader = header
 	cz.Raw = data
+	if err := checkColorbits(cz.Colorbits, 32); err != nil {
+		return err
+	}
+	if size := int(cz.Width) * int(cz.Heigth) * 4; size > len(cz.Raw)-int(cz.HeaderLength) {
+		return fmt.Errorf("%d bytes of pixel data, only %d left", size, len(cz.Raw)-int(cz.HeaderLength))
+	}
 	err := restruct.Unpack(cz.Raw[15:], binary.LittleEndian, &cz.Cz0Header)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	// Preserve extended header bytes between the fixed headers and HeaderLength.
@@ -66,34 +74,37 @@ func (cz *Cz0Image) Load(header CzHeader, data []byte) {
 	}
 	glog.V(6).Infoln("cz0 header ", cz.Cz0Header)
 	cz.OutputInfo = nil
+	return nil
 }
 
 // decompress
 //
 //	Description 解压数据
 //	Receiver cz *Cz0Image
-func (cz *Cz0Image) decompress() {
+//	Return error
+func (cz *Cz0Image) decompress() error {
 	//os.WriteFile("../data/LB_EN/IMAGE/2.lzw", cz.Raw[int(cz.HeaderLength)+cz.OutputInfo.Offset:], 0666)
 	glog.V(6).Infoln("size ", len(cz.Raw))
 	glog.V(0).Infof("Decompress CZ0: %dx%d, Colorbits=%d\n",
     cz.Width, cz.Heigth, cz.Colorbits)
-	pic := image.NewNRGBA(image.Rect(0, 0, int(cz.Width), int(cz.Heigth)))
 	offset := int(cz.HeaderLength)
-	switch cz.Colorbits {
-	case 32:
-		for y := 0; y < int(cz.Heigth); y++ {
-			for x := 0; x < int(cz.Width); x++ {
-				pic.SetNRGBA(x, y, color.NRGBA{
-					R: cz.Raw[offset+0],
-					G: cz.Raw[offset+1],
-					B: cz.Raw[offset+2],
-					A: cz.Raw[offset+3]},
-				)
-				offset += 4
-			}
+	if size := int(cz.Width) * int(cz.Heigth) * 4; size > len(cz.Raw)-offset {
+		return fmt.Errorf("cz0: %d bytes of pixel data, only %d left", size, len(cz.Raw)-offset)
+	}
+	pic := image.NewNRGBA(image.Rect(0, 0, int(cz.Width), int(cz.Heigth)))
+	for y := 0; y < int(cz.Heigth); y++ {
+		for x := 0; x < int(cz.Width); x++ {
+			pic.SetNRGBA(x, y, color.NRGBA{
+				R: cz.Raw[offset+0],
+				G: cz.Raw[offset+1],
+				B: cz.Raw[offset+2],
+				A: cz.Raw[offset+3]},
+			)
+			offset += 4
 		}
 	}
 	cz.Image = pic
+	return nil
 }
 
 // GetImage
@@ -101,11 +112,14 @@ func (cz *Cz0Image) decompress() {
 //	Description 取得解压后的图像数据
 //	Receiver cz *Cz0Image
 //	Return image.Image
-func (cz *Cz0Image) GetImage() image.Image {
+//	Return error
+func (cz *Cz0Image) GetImage() (image.Image, error) {
 	if cz.Image == nil {
-		cz.decompress()
+		if err := cz.decompress(); err != nil {
+			return nil, err
+		}
 	}
-	return cz.Image
+	return cz.Image, nil
 }
 
 // Export
@@ -115,8 +129,8 @@ func (cz *Cz0Image) GetImage() image.Image {
 //	Param w io.Writer
 //	Return error
 func (cz *Cz0Image) Export(w io.Writer) error {
-	if cz.Image == nil {
-		cz.decompress()
+	if _, err := cz.GetImage(); err != nil {
+		return err
 	}
 	return png.Encode(w, cz.Image)
 }
@@ -1,6 +1,7 @@
 package czimage
 
 import (
+	"fmt"
 	"image"
 	"image/color"
 	"image/png"
@@ -17,9 +18,12 @@ type Cz1Image struct {
 	ExtendedHeader []byte        // Raw bytes of extended header (HeaderLength - 15)
 	ColorPanel     []color.NRGBA // []BGRA
 	CzData
+
+	RebuildPalette bool // Import() 4/8位时使用 Quantize 重新生成调色板
+	Dither         bool // Import() 4/8位时使用Floyd–Steinberg抖动
 }
 
-func (cz *Cz1Image) Load(header CzHeader, data []byte) {
+func (cz *Cz1Image) Load(header CzHeader, data []byte) error {
 	cz.CzHeader = header
 	cz.Raw = data
 
@@ -29,6 +33,9 @@ func (cz *Cz1Image) Load(header CzHeader, data []byte) {
 		glog.V(4).Infof("CZ1: Colorbits=%d > 32, normalizing to 8 (indexed palette)\n", cz.Colorbits)
 		cz.Colorbits = 8
 	}
+	if err := checkColorbits(cz.Colorbits, 4, 8, 24, 32); err != nil {
+		return err
+	}
 
 	// Save extended header bytes (between base header and palette/data)
 	if cz.HeaderLength > 15 {
@@ -39,6 +46,9 @@ func (cz *Cz1Image) Load(header CzHeader, data []byte) {
 	offset := int(cz.HeaderLength)
 	if cz.Colorbits == 4 || cz.Colorbits == 8 {
 		colorCount := 1 << cz.Colorbits
+		if colorCount*4 > len(cz.Raw)-offset {
+			return fmt.Errorf("palette of %d colors truncated", colorCount)
+		}
 		cz.ColorPanel = make([]color.NRGBA, colorCount)
 		for i := 0; i < colorCount; i++ {
 			cz.ColorPanel[i] = color.NRGBA{
@@ -51,22 +61,35 @@ func (cz *Cz1Image) Load(header CzHeader, data []byte) {
 		}
 		glog.V(6).Infoln("cz1 colorPanel", len(cz.ColorPanel))
 	}
-	cz.OutputInfo = GetOutputInfo(cz.Raw[offset:])
+	var err error
+	if cz.OutputInfo, err = GetOutputInfo(cz.Raw[offset:]); err != nil {
+		return err
+	}
+	pixels := int(cz.Width) * int(cz.Heigth)
+	return checkRawSize(cz.OutputInfo, (pixels*int(cz.Colorbits)+7)/8)
 }
 
 // decompress
 //
 //	Description 解压数据
 //	Receiver cz *Cz1Image
-func (cz *Cz1Image) decompress() {
-	pic := image.NewNRGBA(image.Rect(0, 0, int(cz.CzHeader.Width), int(cz.CzHeader.Heigth)))
+//	Return error
+func (cz *Cz1Image) decompress() error {
 	offset := int(cz.HeaderLength)
 	if cz.Colorbits == 4 || cz.Colorbits == 8 {
 		offset += (1 << cz.Colorbits) * 4
 	}
-	buf := Decompress(cz.Raw[offset+cz.OutputInfo.Offset:], cz.OutputInfo)
+	buf, err := Decompress(cz.Raw[offset+cz.OutputInfo.Offset:], cz.OutputInfo)
+	if err != nil {
+		return err
+	}
 	glog.V(6).Infoln("uncompress size", len(buf))
 
+	pixels := int(cz.CzHeader.Width) * int(cz.CzHeader.Heigth)
+	if need := (pixels*int(cz.Colorbits) + 7) / 8; len(buf) < need {
+		return fmt.Errorf("cz1: decompressed %d bytes, need %d", len(buf), need)
+	}
+	pic := image.NewNRGBA(image.Rect(0, 0, int(cz.CzHeader.Width), int(cz.CzHeader.Heigth)))
 	switch cz.Colorbits {
 	case 4:
 		i := 0
@@ -106,9 +129,10 @@ func (cz *Cz1Image) decompress() {
 		}
 	case 32:
 		// RGBA direct copy (CZ1 32-bit stores pixels as RGBA)
-		pic.Pix = buf
+		pic.Pix = buf[:pixels*4]
 	}
 	cz.Image = pic
+	return nil
 }
 
 // GetImage
@@ -116,11 +140,14 @@ func (cz *Cz1Image) decompress() {
 //	Description 取得解压后的图像数据
 //	Receiver cz *Cz1Image
 //	Return image.Image
-func (cz *Cz1Image) GetImage() image.Image {
+//	Return error
+func (cz *Cz1Image) GetImage() (image.Image, error) {
 	if cz.Image == nil {
-		cz.decompress()
+		if err := cz.decompress(); err != nil {
+			return nil, err
+		}
 	}
-	return cz.Image
+	return cz.Image, nil
 }
 
 // Export
@@ -130,8 +157,8 @@ func (cz *Cz1Image) GetImage() image.Image {
 //	Param w io.Writer
 //	Return error
 func (cz *Cz1Image) Export(w io.Writer) error {
-	if cz.Image == nil {
-		cz.decompress()
+	if _, err := cz.GetImage(); err != nil {
+		return err
 	}
 	return png.Encode(w, cz.Image)
 }
@@ -147,11 +174,16 @@ func (cz *Cz1Image) Import(r io.Reader, fillSize bool) error {
 	var err error
 	cz.PngImage, err = png.Decode(r)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	return cz.importImage(cz.PngImage, fillSize)
+}
 
+// importImage 压缩图像数据，Import与Encode共用
+func (cz *Cz1Image) importImage(img image.Image, fillSize bool) error {
+	var err error
 	// Convert any PNG format to NRGBA
-	pic := ImageToNRGBA(cz.PngImage)
+	pic := ImageToNRGBA(img)
 
 	width := int(cz.Width)
 	height := int(cz.Heigth)
@@ -166,34 +198,26 @@ func (cz *Cz1Image) Import(r io.Reader, fillSize bool) error {
 
 	var data []byte
 
+	if (cz.Colorbits == 4 || cz.Colorbits == 8) && cz.RebuildPalette {
+		cz.ColorPanel = Quantize(pic, 1<<cz.Colorbits)
+		glog.V(2).Infof("CZ1: rebuilt %d-color palette\n", len(cz.ColorPanel))
+	}
+
 	switch cz.Colorbits {
 	case 4:
 		// 4-bit indexed: find closest palette entry for each pixel
+		indexes := MapPalette(pic, cz.ColorPanel, cz.Dither)
 		data = make([]byte, (width*height+1)/2)
-		i := 0
-		for y := 0; y < height; y++ {
-			for x := 0; x < width; x++ {
-				c := pic.NRGBAAt(x, y)
-				idx := cz.findClosestPaletteEntry(c)
-				if i%2 == 0 {
-					data[i/2] = idx & 0x0F
-				} else {
-					data[i/2] |= (idx & 0x0F) << 4
-				}
-				i++
+		for i, idx := range indexes {
+			if i%2 == 0 {
+				data[i/2] = idx & 0x0F
+			} else {
+				data[i/2] |= (idx & 0x0F) << 4
 			}
 		}
 	case 8:
 		// 8-bit indexed: find closest palette entry for each pixel
-		data = make([]byte, width*height)
-		i := 0
-		for y := 0; y < height; y++ {
-			for x := 0; x < width; x++ {
-				c := pic.NRGBAAt(x, y)
-				data[i] = cz.findClosestPaletteEntry(c)
-				i++
-			}
-		}
+		data = MapPalette(pic, cz.ColorPanel, cz.Dither)
 	case 24:
 		// RGB: 3 bytes per pixel
 		data = make([]byte, width*height*3)
@@ -212,11 +236,7 @@ func (cz *Cz1Image) Import(r io.Reader, fillSize bool) error {
 		data = pic.Pix
 	}
 
-	blockSize := 0
-	if len(cz.OutputInfo.BlockInfo) != 0 {
-		blockSize = int(cz.OutputInfo.BlockInfo[0].CompressedSize)
-	}
-	cz.Raw, cz.OutputInfo = Compress(data, blockSize)
+	cz.Raw, cz.OutputInfo = cz.compress(data)
 
 	cz.OutputInfo.TotalRawSize = 0
 	cz.OutputInfo.TotalCompressedSize = 0
@@ -229,27 +249,6 @@ func (cz *Cz1Image) Import(r io.Reader, fillSize bool) error {
 	return nil
 }
 
-// findClosestPaletteEntry finds the palette index that best matches the given color
-func (cz *Cz1Image) findClosestPaletteEntry(c color.NRGBA) uint8 {
-	bestIdx := uint8(0)
-	bestDist := int(^uint(0) >> 1) // max int
-	for i, pc := range cz.ColorPanel {
-		dr := int(c.R) - int(pc.R)
-		dg := int(c.G) - int(pc.G)
-		db := int(c.B) - int(pc.B)
-		da := int(c.A) - int(pc.A)
-		dist := dr*dr + dg*dg + db*db + da*da
-		if dist == 0 {
-			return uint8(i)
-		}
-		if dist < bestDist {
-			bestDist = dist
-			bestIdx = uint8(i)
-		}
-	}
-	return bestIdx
-}
-
 func (cz *Cz1Image) Write(w io.Writer) error {
 	var err error
 	// Fix magic byte (same pattern as CZ3/CZ4)
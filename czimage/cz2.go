@@ -1,6 +1,7 @@
 package czimage
 
 import (
+	"fmt"
 	"image"
 	"image/color"
 	"image/png"
@@ -25,53 +26,64 @@ type Cz2Image struct {
 	CzData
 }
 
-func (cz *Cz2Image) Load(header CzHeader, data []byte) {
+func (cz *Cz2Image) Load(header CzHeader, data []byte) error {
 	cz.CzHeader = header
 	cz.Raw = data
 
+	// 解码只支持8位调色板
+	if err := checkColorbits(cz.Colorbits, 8); err != nil {
+		return err
+	}
 	offset := int(cz.HeaderLength)
-	if cz.Colorbits == 4 || cz.Colorbits == 8 {
-		cz.ColorPanel = make([]color.NRGBA, 1<<cz.Colorbits)
-		for i := 0; i < (1 << cz.Colorbits); i++ {
-			cz.ColorPanel[i] = color.NRGBA{
-				B: cz.Raw[offset+0],
-				G: cz.Raw[offset+1],
-				R: cz.Raw[offset+2],
-				A: cz.Raw[offset+3],
-			}
-			offset += 4
+	if 4<<cz.Colorbits > len(cz.Raw)-offset {
+		return fmt.Errorf("palette of %d colors truncated", 1<<cz.Colorbits)
+	}
+	cz.ColorPanel = make([]color.NRGBA, 1<<cz.Colorbits)
+	for i := 0; i < (1 << cz.Colorbits); i++ {
+		cz.ColorPanel[i] = color.NRGBA{
+			B: cz.Raw[offset+0],
+			G: cz.Raw[offset+1],
+			R: cz.Raw[offset+2],
+			A: cz.Raw[offset+3],
 		}
-		glog.V(6).Infoln("cz2 colorPanel", len(cz.ColorPanel))
+		offset += 4
 	}
+	glog.V(6).Infoln("cz2 colorPanel", len(cz.ColorPanel))
 
-	cz.OutputInfo = GetOutputInfo(cz.Raw[offset:])
+	var err error
+	if cz.OutputInfo, err = GetOutputInfo(cz.Raw[offset:]); err != nil {
+		return err
+	}
 	glog.V(6).Infoln(cz.OutputInfo)
+	return checkRawSize(cz.OutputInfo, int(cz.Width)*int(cz.Heigth))
 }
 
 // decompress
 //
 //	Description 解压数据
 //	Receiver cz *Cz1Image
-func (cz *Cz2Image) decompress() {
-	pic := image.NewNRGBA(image.Rect(0, 0, int(cz.CzHeader.Width), int(cz.CzHeader.Heigth)))
-	offset := int(cz.HeaderLength)
-	if cz.Colorbits == 4 || cz.Colorbits == 8 {
-		offset += 1 << (cz.Colorbits + 2)
+//	Return error
+func (cz *Cz2Image) decompress() error {
+	offset := int(cz.HeaderLength) + 1<<(cz.Colorbits+2)
+	buf, err := Decompress2(cz.Raw[offset+cz.OutputInfo.Offset:], cz.OutputInfo)
+	if err != nil {
+		return err
 	}
-	buf := Decompress2(cz.Raw[offset+cz.OutputInfo.Offset:], cz.OutputInfo)
 	glog.V(6).Infoln("uncompress size", len(buf))
 	//_ = os.WriteFile("C:\\Users\\wetor\\Desktop\\Prototype\\CZ2\\32\\明朝32.cz2.bin", buf, 0666)
-	switch cz.Colorbits {
-	case 8:
-		i := 0
-		for y := 0; y < int(cz.CzHeader.Heigth); y++ {
-			for x := 0; x < int(cz.CzHeader.Width); x++ {
-				pic.SetNRGBA(x, y, cz.ColorPanel[buf[i]])
-				i++
-			}
+	if need := int(cz.CzHeader.Width) * int(cz.CzHeader.Heigth); len(buf) < need {
+		return fmt.Errorf("cz2: decompressed %d bytes, need %d", len(buf), need)
+	}
+	pic := image.NewNRGBA(image.Rect(0, 0, int(cz.CzHeader.Width), int(cz.CzHeader.Heigth)))
+	i := 0
+	for y := 0; y < int(cz.CzHeader.Heigth); y++ {
+		for x := 0; x < int(cz.CzHeader.Width); x++ {
+			pic.SetNRGBA(x, y, cz.ColorPanel[buf[i]])
+			i++
 		}
 	}
 	cz.Image = pic
+	return nil
 }
 
 // GetImage
@@ -79,11 +91,14 @@ func (cz *Cz2Image) decompress() {
 //	Description 取得解压后的图像数据
 //	Receiver cz *Cz1Image
 //	Return image.Image
-func (cz *Cz2Image) GetImage() image.Image {
+//	Return error
+func (cz *Cz2Image) GetImage() (image.Image, error) {
 	if cz.Image == nil {
-		cz.decompress()
+		if err := cz.decompress(); err != nil {
+			return nil, err
+		}
 	}
-	return cz.Image
+	return cz.Image, nil
 }
 
 // Export
@@ -93,8 +108,8 @@ func (cz *Cz2Image) GetImage() image.Image {
 //	Param w io.Writer
 //	Return error
 func (cz *Cz2Image) Export(w io.Writer) error {
-	if cz.Image == nil {
-		cz.decompress()
+	if _, err := cz.GetImage(); err != nil {
+		return err
 	}
 	return png.Encode(w, cz.Image)
 }
@@ -103,11 +118,16 @@ func (cz *Cz2Image) Import(r io.Reader, fillSize bool) error {
 	var err error
 	cz.PngImage, err = png.Decode(r)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	return cz.importImage(cz.PngImage, fillSize)
+}
+
+// importImage 压缩图像数据，Import与Encode共用
+func (cz *Cz2Image) importImage(img image.Image, fillSize bool) error {
 	// Yoremi Patch 3: safe type conversion instead of direct assertion
 	var pic *image.NRGBA
-	switch src := cz.PngImage.(type) {
+	switch src := img.(type) {
 	case *image.NRGBA:
 		pic = src
 	default:
@@ -145,19 +165,23 @@ func (cz *Cz2Image) Import(r io.Reader, fillSize bool) error {
 			i++
 		}
 	}
-	blockSize := 0
-	targetRawSizes := make([]int, 0, len(cz.OutputInfo.BlockInfo))
+	blockSize := cz.blockSize()
+	targetRawSizes := make([]int, 0)
 	targetRawTotal := 0
-	if len(cz.OutputInfo.BlockInfo) != 0 {
-		blockSize = int(cz.OutputInfo.BlockInfo[0].CompressedSize)
+	if cz.BlockSize == 0 && cz.OutputInfo != nil {
 		for _, block := range cz.OutputInfo.BlockInfo {
 			targetRawSizes = append(targetRawSizes, int(block.RawSize))
 			targetRawTotal += int(block.RawSize)
 		}
 	}
-	if targetRawTotal == len(data) {
+	switch {
+	case targetRawTotal == len(data) && cz.Jobs > 0:
+		cz.Raw, cz.OutputInfo = Compress2WithRawSizesParallel(data, targetRawSizes, cz.Jobs)
+	case targetRawTotal == len(data):
 		cz.Raw, cz.OutputInfo = Compress2WithRawSizes(data, targetRawSizes)
-	} else {
+	case cz.Jobs > 0:
+		cz.Raw, cz.OutputInfo = Compress2Parallel(data, blockSize, cz.Jobs)
+	default:
 		cz.Raw, cz.OutputInfo = Compress2(data, blockSize)
 	}
 
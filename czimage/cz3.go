@@ -38,26 +38,38 @@ type Cz3Image struct {
 //	Receiver cz *Cz3Image
 //	Param header CzHeader
 //	Param data []byte
-func (cz *Cz3Image) Load(header CzHeader, data []byte) {
+//	Return error
+func (cz *Cz3Image) Load(header CzHeader, data []byte) error {
 	cz.CzHeader = header
 	cz.Raw = data
 	err := restruct.Unpack(cz.Raw[15:], binary.LittleEndian, &cz.Cz3Header)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	glog.V(6).Infoln("cz3 header ", cz.Cz3Header)
-	cz.OutputInfo = GetOutputInfo(cz.Raw[int(cz.HeaderLength):])
+	if err = checkColorbits(cz.Colorbits, 24, 32); err != nil {
+		return err
+	}
+	if cz.OutputInfo, err = GetOutputInfo(cz.Raw[int(cz.HeaderLength):]); err != nil {
+		return err
+	}
+	return checkRawSize(cz.OutputInfo, int(cz.Width)*int(cz.Heigth)*int(cz.Colorbits/8))
 }
 
 // decompress
 //
 //	Description 解压数据
 //	Receiver cz *Cz3Image
-func (cz *Cz3Image) decompress() {
+//	Return error
+func (cz *Cz3Image) decompress() error {
 	//os.WriteFile("../data/LB_EN/IMAGE/2.lzw", cz.Raw[int(cz.HeaderLength)+cz.OutputInfo.Offset:], 0666)
-	buf := Decompress(cz.Raw[int(cz.HeaderLength)+cz.OutputInfo.Offset:], cz.OutputInfo)
+	buf, err := Decompress(cz.Raw[int(cz.HeaderLength)+cz.OutputInfo.Offset:], cz.OutputInfo)
+	if err != nil {
+		return err
+	}
 	glog.V(6).Infoln("uncompress size ", len(buf))
-	cz.Image = LineDiff(&cz.CzHeader, buf)
+	cz.Image, err = LineDiff(&cz.CzHeader, buf)
+	return err
 }
 
 // GetImage
@@ -65,11 +77,14 @@ func (cz *Cz3Image) decompress() {
 //	Description 取得解压后的图像数据
 //	Receiver cz *Cz3Image
 //	Return image.Image
-func (cz *Cz3Image) GetImage() image.Image {
+//	Return error
+func (cz *Cz3Image) GetImage() (image.Image, error) {
 	if cz.Image == nil {
-		cz.decompress()
+		if err := cz.decompress(); err != nil {
+			return nil, err
+		}
 	}
-	return cz.Image
+	return cz.Image, nil
 }
 
 // Export
@@ -79,8 +94,8 @@ func (cz *Cz3Image) GetImage() image.Image {
 //	Param w io.Writer
 //	Return error
 func (cz *Cz3Image) Export(w io.Writer) error {
-	if cz.Image == nil {
-		cz.decompress()
+	if _, err := cz.GetImage(); err != nil {
+		return err
 	}
 
 	// PATCH YOREMI: S'assurer que l'image est en NRGBA pour avoir 4 bytes/pixel
@@ -114,16 +129,20 @@ func (cz *Cz3Image) Import(r io.Reader, fillSize bool) error {
 	if err != nil {
 		return err
 	}
+	return cz.importImage(cz.PngImage)
+}
 
+// importImage 压缩图像数据，Import与Encode共用
+func (cz *Cz3Image) importImage(img image.Image) error {
 	// PATCH YOREMI: LOG le format PNG source pour debug
 	glog.V(0).Infof("Import: PNG source type=%T, bounds=%v\n",
-		cz.PngImage, cz.PngImage.Bounds())
+		img, img.Bounds())
 
 	// FORCER conversion en NRGBA (4 bytes/pixel)
-	pic, ok := cz.PngImage.(*image.NRGBA)
+	pic, ok := img.(*image.NRGBA)
 	if !ok {
-		glog.V(0).Infof("Import: Converting to NRGBA (source was %T)\n", cz.PngImage)
-		pic = ImageToNRGBA(cz.PngImage)
+		glog.V(0).Infof("Import: Converting to NRGBA (source was %T)\n", img)
+		pic = ImageToNRGBA(img)
 	}
 
 	// VÉRIFICATION CRITIQUE: pic.Pix doit avoir exactement width * height * 4 bytes
@@ -161,12 +180,8 @@ func (cz *Cz3Image) Import(r io.Reader, fillSize bool) error {
 		glog.V(0).Infof("Import: DiffLine OK, generated %d bytes\n", len(data))
 	}
 
-	blockSize := 0
-	if len(cz.OutputInfo.BlockInfo) != 0 {
-		blockSize = int(cz.OutputInfo.BlockInfo[0].CompressedSize)
-	}
 	glog.V(6).Infoln(cz.OutputInfo)
-	cz.Raw, cz.OutputInfo = Compress(data, blockSize)
+	cz.Raw, cz.OutputInfo = cz.compress(data)
 	glog.V(6).Infoln(cz.OutputInfo)
 	cz.OutputInfo.TotalRawSize = 0
 	cz.OutputInfo.TotalCompressedSize = 0
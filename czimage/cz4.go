@@ -25,38 +25,53 @@ type Cz4Image struct {
 //  Receiver cz *Cz4Image
 //  Param header CzHeader
 //  Param data []byte
+//  Return error
 //
-func (cz *Cz4Image) Load(header CzHeader, data []byte) {
+func (cz *Cz4Image) Load(header CzHeader, data []byte) error {
 	cz.CzHeader = header
 	cz.Raw = data
 	err := restruct.Unpack(cz.Raw[15:], binary.LittleEndian, &cz.Cz3Header)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	glog.V(6).Infoln("cz4 header ", cz.Cz3Header)
-	cz.OutputInfo = GetOutputInfo(cz.Raw[int(cz.HeaderLength):])
+	if err = checkColorbits(cz.Colorbits, 32); err != nil {
+		return err
+	}
+	if cz.OutputInfo, err = GetOutputInfo(cz.Raw[int(cz.HeaderLength):]); err != nil {
+		return err
+	}
+	return checkRawSize(cz.OutputInfo, int(cz.Width)*int(cz.Heigth)*4)
 }
 
 // decompress
 //  Description Decompress and decode CZ4 data
 //  Receiver cz *Cz4Image
+//  Return error
 //
-func (cz *Cz4Image) decompress() {
-	buf := Decompress(cz.Raw[int(cz.HeaderLength)+cz.OutputInfo.Offset:], cz.OutputInfo)
+func (cz *Cz4Image) decompress() error {
+	buf, err := Decompress(cz.Raw[int(cz.HeaderLength)+cz.OutputInfo.Offset:], cz.OutputInfo)
+	if err != nil {
+		return err
+	}
 	glog.V(6).Infoln("uncompress size ", len(buf))
-	cz.Image = LineDiff4(&cz.CzHeader, buf)
+	cz.Image, err = LineDiff4(&cz.CzHeader, buf)
+	return err
 }
 
 // GetImage
 //  Description Get decoded image
 //  Receiver cz *Cz4Image
 //  Return image.Image
+//  Return error
 //
-func (cz *Cz4Image) GetImage() image.Image {
+func (cz *Cz4Image) GetImage() (image.Image, error) {
 	if cz.Image == nil {
-		cz.decompress()
+		if err := cz.decompress(); err != nil {
+			return nil, err
+		}
 	}
-	return cz.Image
+	return cz.Image, nil
 }
 
 // Export
@@ -66,8 +81,8 @@ func (cz *Cz4Image) GetImage() image.Image {
 //  Return error
 //
 func (cz *Cz4Image) Export(w io.Writer) error {
-	if cz.Image == nil {
-		cz.decompress()
+	if _, err := cz.GetImage(); err != nil {
+		return err
 	}
 
 	var nrgbaImg *image.NRGBA
@@ -97,15 +112,24 @@ func (cz *Cz4Image) Import(r io.Reader, fillSize bool) error {
 	if err != nil {
 		return err
 	}
+	return cz.importImage(cz.PngImage)
+}
 
+// importImage 压缩图像数据，Import与Encode共用
+//
+//	Description 分离RGB与Alpha通道，逐行差分后压缩
+//	Receiver cz *Cz4Image
+//	Param img image.Image
+//	Return error
+func (cz *Cz4Image) importImage(img image.Image) error {
 	glog.V(0).Infof("Import CZ4: PNG source type=%T, bounds=%v\n",
-		cz.PngImage, cz.PngImage.Bounds())
+		img, img.Bounds())
 
 	// Force NRGBA conversion
-	pic, ok := cz.PngImage.(*image.NRGBA)
+	pic, ok := img.(*image.NRGBA)
 	if !ok {
-		glog.V(0).Infof("Import CZ4: Converting to NRGBA (source was %T)\n", cz.PngImage)
-		pic = ImageToNRGBA(cz.PngImage)
+		glog.V(0).Infof("Import CZ4: Converting to NRGBA (source was %T)\n", img)
+		pic = ImageToNRGBA(img)
 	}
 
 	// Verify pixel buffer size
@@ -130,11 +154,7 @@ func (cz *Cz4Image) Import(r io.Reader, fillSize bool) error {
 		glog.V(0).Infof("Import CZ4: DiffLine4 OK, generated %d bytes\n", len(data))
 	}
 
-	blockSize := 0
-	if len(cz.OutputInfo.BlockInfo) != 0 {
-		blockSize = int(cz.OutputInfo.BlockInfo[0].CompressedSize)
-	}
-	cz.Raw, cz.OutputInfo = Compress(data, blockSize)
+	cz.Raw, cz.OutputInfo = cz.compress(data)
 	cz.OutputInfo.TotalRawSize = 0
 	cz.OutputInfo.TotalCompressedSize = 0
 	for _, block := range cz.OutputInfo.BlockInfo {
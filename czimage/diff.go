@@ -0,0 +1,240 @@
+package czimage
+
+import (
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+)
+
+// diffCellSize 合并变化区域时的网格大小
+const diffCellSize = 16
+
+// 比较结果状态
+const (
+	DiffSame    = "same"
+	DiffChanged = "changed"
+	DiffAdded   = "added"
+	DiffRemoved = "removed"
+	DiffError   = "error"
+)
+
+// DiffResult 两个cz的比较结果
+type DiffResult struct {
+	Name         string            `json:"name"`
+	Status       string            `json:"status"`
+	Orig         *Metadata         `json:"orig,omitempty"`
+	New          *Metadata         `json:"new,omitempty"`
+	Header       []string          `json:"header,omitempty"`        // 不同的头字段
+	SizeMismatch bool              `json:"size_mismatch,omitempty"` // 宽高不同，以原cz为source导入时会被拒绝
+	Pixels       int               `json:"diff_pixels"`             // 不同的像素数
+	Regions      []image.Rectangle `json:"regions,omitempty"`       // 变化的区域
+	Error        string            `json:"error,omitempty"`
+
+	// 报告用的缩略图data URI，相同的图像没有缩略图，不保留完整图像以便比较大量CG
+	OrigThumb      template.URL `json:"-"`
+	NewThumb       template.URL `json:"-"`
+	HighlightThumb template.URL `json:"-"` // 标出变化区域的新图像，宽高不同时为空
+}
+
+func (r *DiffResult) String() string {
+	switch r.Status {
+	case DiffError:
+		return fmt.Sprintf("%s: error: %s", r.Name, r.Error)
+	case DiffChanged:
+		s := fmt.Sprintf("%s: changed: %d pixel(s) in %d region(s)", r.Name, r.Pixels, len(r.Regions))
+		if len(r.Header) != 0 {
+			s += fmt.Sprintf(", header %v", r.Header)
+		}
+		if r.SizeMismatch {
+			s += " (size mismatch, re-import would be rejected)"
+		}
+		return s
+	}
+	return fmt.Sprintf("%s: %s", r.Name, r.Status)
+}
+
+// DiffCz 比较两个cz文件
+//
+//	Description 解码两侧cz，比较头字段（宽高、位置、色深等）与像素。
+//	  origData或newData为nil时视为新增或删除的文件。
+//	  不同时只保留缩略图，解码后的图像不会留在结果中
+//	Param name string 用于报告的名称
+//	Param origData []byte 原cz数据
+//	Param newData []byte 新cz数据
+//	Return *DiffResult
+func DiffCz(name string, origData, newData []byte) (result *DiffResult) {
+	result = &DiffResult{Name: name}
+	defer func() {
+		if e := recover(); e != nil {
+			result.Status, result.Error = DiffError, fmt.Sprint(e)
+		}
+	}()
+	var err error
+	var origImage, newImage, highlight *image.NRGBA
+	if origData != nil {
+		if result.Orig, origImage, err = decodeForDiff(origData); err != nil {
+			result.Status, result.Error = DiffError, fmt.Sprintf("orig: %v", err)
+			return result
+		}
+	}
+	if newData != nil {
+		if result.New, newImage, err = decodeForDiff(newData); err != nil {
+			result.Status, result.Error = DiffError, fmt.Sprintf("new: %v", err)
+			return result
+		}
+	}
+	switch {
+	case origData == nil:
+		result.Status = DiffAdded
+	case newData == nil:
+		result.Status = DiffRemoved
+	default:
+		result.Header = diffMetadata(result.Orig, result.New)
+		result.SizeMismatch = result.Orig.Width != result.New.Width || result.Orig.Height != result.New.Height
+		if result.SizeMismatch {
+			result.Pixels = origImage.Rect.Dx() * origImage.Rect.Dy()
+		} else {
+			var mask []bool
+			result.Pixels, mask = CompareImages(origImage, newImage)
+			result.Regions = diffRegions(mask, newImage.Rect.Dx(), newImage.Rect.Dy())
+			if result.Pixels != 0 {
+				highlight = highlightDiff(newImage, mask, result.Regions)
+			}
+		}
+		result.Status = DiffSame
+		if result.Pixels == 0 && len(result.Header) == 0 {
+			return result
+		}
+		result.Status = DiffChanged
+	}
+
+	for _, t := range []struct {
+		uri *template.URL
+		img *image.NRGBA
+	}{{&result.OrigThumb, origImage}, {&result.NewThumb, newImage}, {&result.HighlightThumb, highlight}} {
+		if *t.uri, err = dataURI(t.img); err != nil {
+			result.Status, result.Error = DiffError, fmt.Sprintf("thumbnail: %v", err)
+			return result
+		}
+	}
+	return result
+}
+
+// decodeForDiff 解码cz并读取文件头
+func decodeForDiff(data []byte) (*Metadata, *image.NRGBA, error) {
+	cz, err := ReadCzImage(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	img, err := cz.GetImage()
+	if err != nil {
+		return nil, nil, err
+	}
+	return GetMetadata(cz), ImageToNRGBA(img), nil
+}
+
+// CompareImages 逐像素比较两个相同大小的图像
+//
+//	Description 两侧都完全透明的像素视为相同
+//	Param a *image.NRGBA
+//	Param b *image.NRGBA
+//	Return pixels int 不同的像素数
+//	Return mask []bool 按行排列，true为不同
+func CompareImages(a, b *image.NRGBA) (pixels int, mask []bool) {
+	w, h := a.Rect.Dx(), a.Rect.Dy()
+	mask = make([]bool, w*h)
+	for y := 0; y < h; y++ {
+		pa := a.Pix[y*a.Stride : y*a.Stride+w*4]
+		pb := b.Pix[y*b.Stride : y*b.Stride+w*4]
+		for x := 0; x < w; x++ {
+			ca, cb := pa[x*4:x*4+4], pb[x*4:x*4+4]
+			if ca[3] == 0 && cb[3] == 0 {
+				continue
+			}
+			if ca[0] != cb[0] || ca[1] != cb[1] || ca[2] != cb[2] || ca[3] != cb[3] {
+				mask[y*w+x] = true
+				pixels++
+			}
+		}
+	}
+	return pixels, mask
+}
+
+// diffRegions 将变化的像素按网格合并为矩形
+//
+//	Description 有变化的网格按8邻接连成一片，每片取包含其中变化像素的最小矩形
+func diffRegions(mask []bool, w, h int) []image.Rectangle {
+	cw, ch := (w+diffCellSize-1)/diffCellSize, (h+diffCellSize-1)/diffCellSize
+	cells := make([]image.Rectangle, cw*ch)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if mask[y*w+x] {
+				i := y/diffCellSize*cw + x/diffCellSize
+				cells[i] = cells[i].Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	var regions []image.Rectangle
+	seen := make([]bool, len(cells))
+	for start := range cells {
+		if seen[start] || cells[start].Empty() {
+			continue
+		}
+		var region image.Rectangle
+		stack := []int{start}
+		seen[start] = true
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			region = region.Union(cells[i])
+			cx, cy := i%cw, i/cw
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := cx+dx, cy+dy
+					if nx < 0 || ny < 0 || nx >= cw || ny >= ch {
+						continue
+					}
+					if n := ny*cw + nx; !seen[n] && !cells[n].Empty() {
+						seen[n] = true
+						stack = append(stack, n)
+					}
+				}
+			}
+		}
+		regions = append(regions, region)
+	}
+	return regions
+}
+
+// highlightDiff 淡化未变化的像素，变化的像素标红，并框出变化区域
+func highlightDiff(pic *image.NRGBA, mask []bool, regions []image.Rectangle) *image.NRGBA {
+	w, h := pic.Rect.Dx(), pic.Rect.Dy()
+	out := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := pic.NRGBAAt(pic.Rect.Min.X+x, pic.Rect.Min.Y+y)
+			if mask[y*w+x] {
+				// 保留一部分原色，便于辨认改动的内容
+				c = color.NRGBA{R: uint8(0xFF/2 + int(c.R)/2), G: c.G / 3, B: c.B / 3, A: 0xFF}
+			} else {
+				gray := uint8((int(c.R) + int(c.G) + int(c.B)) / 3)
+				c = color.NRGBA{R: gray, G: gray, B: gray, A: c.A / 4}
+			}
+			out.SetNRGBA(x, y, c)
+		}
+	}
+	frame := color.NRGBA{R: 0xFF, A: 0xFF}
+	for _, r := range regions {
+		r = r.Inset(-1).Intersect(out.Rect)
+		for x := r.Min.X; x < r.Max.X; x++ {
+			out.SetNRGBA(x, r.Min.Y, frame)
+			out.SetNRGBA(x, r.Max.Y-1, frame)
+		}
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			out.SetNRGBA(r.Min.X, y, frame)
+			out.SetNRGBA(r.Max.X-1, y, frame)
+		}
+	}
+	return out
+}
@@ -0,0 +1,104 @@
+package czimage
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// encodeCz 编码并写出cz
+func encodeCz(t *testing.T, img image.Image, opt EncodeOptions) []byte {
+	t.Helper()
+	cz, err := Encode(img, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err = cz.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDiffCz(t *testing.T) {
+	src := testImage(64, 40, false)
+	orig := encodeCz(t, src, EncodeOptions{Type: "cz3", X: 1})
+
+	if r := DiffCz("same", orig, orig); r.Status != DiffSame || r.Pixels != 0 || r.OrigThumb != "" {
+		t.Errorf("same: %v", r)
+	}
+
+	// 两处相距较远的改动
+	edited := ImageToNRGBA(src)
+	for y := 2; y < 5; y++ {
+		for x := 3; x < 6; x++ {
+			edited.SetNRGBA(x, y, color.NRGBA{R: 1, G: 2, B: 3, A: 0xFF})
+		}
+	}
+	edited.SetNRGBA(60, 38, color.NRGBA{A: 0x80})
+	r := DiffCz("edited", orig, encodeCz(t, edited, EncodeOptions{Type: "cz3", X: 1}))
+	if r.Status != DiffChanged || r.Pixels != 10 || len(r.Header) != 0 || r.SizeMismatch {
+		t.Fatalf("edited: %v", r)
+	}
+	want := []image.Rectangle{image.Rect(3, 2, 6, 5), image.Rect(60, 38, 61, 39)}
+	if len(r.Regions) != 2 || r.Regions[0] != want[0] || r.Regions[1] != want[1] {
+		t.Errorf("regions %v, want %v", r.Regions, want)
+	}
+	if r.OrigThumb == "" || r.NewThumb == "" || r.HighlightThumb == "" {
+		t.Errorf("edited: missing thumbnails")
+	}
+	_, mask := CompareImages(ImageToNRGBA(src), edited)
+	if c := highlightDiff(edited, mask, r.Regions).NRGBAAt(4, 3); c.R < 0x7F || c.G != 0 || c.A != 0xFF {
+		t.Errorf("changed pixel not highlighted: %v", c)
+	}
+
+	r = DiffCz("moved", orig, encodeCz(t, src, EncodeOptions{Type: "cz3", X: 2, Colorbits: 32}))
+	if r.Status != DiffChanged || r.Pixels != 0 || len(r.Header) != 1 || !strings.HasPrefix(r.Header[0], "position") {
+		t.Errorf("moved: %v", r)
+	}
+
+	r = DiffCz("resized", orig, encodeCz(t, testImage(32, 40, false), EncodeOptions{Type: "cz3", X: 1}))
+	if r.Status != DiffChanged || !r.SizeMismatch || r.HighlightThumb != "" {
+		t.Errorf("resized: %v", r)
+	}
+
+	if r = DiffCz("added", nil, orig); r.Status != DiffAdded || r.NewThumb == "" {
+		t.Errorf("added: %v", r)
+	}
+	if r = DiffCz("removed", orig, nil); r.Status != DiffRemoved || r.OrigThumb == "" {
+		t.Errorf("removed: %v", r)
+	}
+	if r = DiffCz("bad", orig, []byte("CZ3\x00truncated header")); r.Status != DiffError {
+		t.Errorf("bad: %v", r)
+	}
+}
+
+func TestWriteDiffReport(t *testing.T) {
+	src := testImage(300, 20, false)
+	orig := encodeCz(t, src, EncodeOptions{Type: "cz3"})
+	edited := ImageToNRGBA(src)
+	edited.SetNRGBA(0, 0, color.NRGBA{R: 0xFF, A: 0xFF})
+	results := []*DiffResult{
+		DiffCz("a<b>.cz3", orig, encodeCz(t, edited, EncodeOptions{Type: "cz3"})),
+		DiffCz("same.cz3", orig, orig),
+		DiffCz("new.cz3", nil, orig),
+	}
+	buf := &bytes.Buffer{}
+	if err := WriteDiffReport(buf, "report", results); err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+	for _, s := range []string{"a&lt;b&gt;.cz3", "data:image/png;base64,", "1 unchanged image(s)", "new.cz3"} {
+		if !strings.Contains(html, s) {
+			t.Errorf("report does not contain %q", s)
+		}
+	}
+	if n := strings.Count(html, "<img "); n != 4 {
+		t.Errorf("%d thumbnails, want 4", n)
+	}
+	if thumb := Thumbnail(ImageToNRGBA(src), DiffThumbnailSize); thumb.Rect.Dx() != 256 || thumb.Rect.Dy() != 17 {
+		t.Errorf("thumbnail %v", thumb.Rect)
+	}
+}
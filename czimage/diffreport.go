@@ -0,0 +1,128 @@
+package czimage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"html/template"
+	"image"
+	"image/png"
+	"io"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// DiffThumbnailSize 报告中缩略图的最大宽高
+const DiffThumbnailSize = 256
+
+// Thumbnail 等比缩小图像，使宽高都不超过size
+//
+//	Param img *image.NRGBA
+//	Param size int
+//	Return *image.NRGBA 不需要缩小时返回原图像
+func Thumbnail(img *image.NRGBA, size int) *image.NRGBA {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+	if w >= h {
+		w, h = size, h*size/w
+	} else {
+		w, h = w*size/h, size
+	}
+	if w == 0 {
+		w = 1
+	}
+	if h == 0 {
+		h = 1
+	}
+	out := image.NewNRGBA(image.Rect(0, 0, w, h))
+	xdraw.ApproxBiLinear.Scale(out, out.Rect, img, img.Rect, xdraw.Src, nil)
+	return out
+}
+
+// dataURI 缩略图的png data URI
+func dataURI(img *image.NRGBA) (template.URL, error) {
+	if img == nil || img.Rect.Empty() {
+		return "", nil
+	}
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, Thumbnail(img, DiffThumbnailSize)); err != nil {
+		return "", err
+	}
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+}
+
+// WriteDiffReport 生成静态html比较报告
+//
+//	Description 缩略图以data URI嵌入，报告为单个文件。
+//	  相同的图像只列出名称
+//	Param w io.Writer
+//	Param title string
+//	Param results []*DiffResult
+//	Return error
+func WriteDiffReport(w io.Writer, title string, results []*DiffResult) error {
+	report := struct {
+		Title   string
+		Counts  map[string]int
+		Entries []*DiffResult
+		Same    []string
+	}{Title: title, Counts: make(map[string]int)}
+	for _, r := range results {
+		report.Counts[r.Status]++
+		if r.Status == DiffSame {
+			report.Same = append(report.Same, r.Name)
+			continue
+		}
+		report.Entries = append(report.Entries, r)
+	}
+	return diffReportTemplate.Execute(w, report)
+}
+
+var diffReportTemplate = template.Must(template.New("diff").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 1em 2em; }
+.entry { border-top: 1px solid #ccc; padding: 0.5em 0; }
+.entry h2 { font-size: 1.1em; margin: 0.3em 0; }
+.changed h2 { color: #b60; } .added h2 { color: #080; } .removed h2, .error h2 { color: #c00; }
+.warn { color: #c00; font-weight: bold; }
+figure { display: inline-block; margin: 0 1em 0 0; vertical-align: top; }
+figcaption { font-size: 0.8em; color: #666; }
+figure img { max-width: 256px; max-height: 256px; border: 1px solid #999; image-rendering: pixelated;
+  background: repeating-conic-gradient(#ddd 0% 25%, #fff 0% 50%) 0 0 / 16px 16px; }
+table { border-collapse: collapse; font-size: 0.9em; }
+td, th { padding: 0 0.6em; text-align: left; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<table>
+<tr><th>changed</th><td>{{index .Counts "changed"}}</td></tr>
+<tr><th>added</th><td>{{index .Counts "added"}}</td></tr>
+<tr><th>removed</th><td>{{index .Counts "removed"}}</td></tr>
+<tr><th>error</th><td>{{index .Counts "error"}}</td></tr>
+<tr><th>same</th><td>{{index .Counts "same"}}</td></tr>
+</table>
+{{range .Entries}}
+<div class="entry {{.Status}}">
+<h2>{{.Name}} &mdash; {{.Status}}</h2>
+{{if .Error}}<p class="warn">{{.Error}}</p>{{end}}
+{{if .SizeMismatch}}<p class="warn">Size changed: re-importing this PNG into the original CZ would be rejected.</p>{{end}}
+{{if .Header}}<ul>{{range .Header}}<li>{{.}}</li>{{end}}</ul>{{end}}
+{{if eq .Status "changed"}}<p>{{.Pixels}} pixel(s) differ{{if .Regions}} in {{len .Regions}} region(s):{{range .Regions}} {{.}}{{end}}{{end}}</p>{{end}}
+{{if .OrigThumb}}<figure><img src="{{.OrigThumb}}"><figcaption>original{{with .Orig}} {{.Type}} {{.Width}}x{{.Height}}{{end}}</figcaption></figure>{{end}}
+{{if .NewThumb}}<figure><img src="{{.NewThumb}}"><figcaption>new{{with .New}} {{.Type}} {{.Width}}x{{.Height}}{{end}}</figcaption></figure>{{end}}
+{{if .HighlightThumb}}<figure><img src="{{.HighlightThumb}}"><figcaption>changes</figcaption></figure>{{end}}
+</div>
+{{end}}
+{{if .Same}}
+<details class="entry"><summary>{{len .Same}} unchanged image(s)</summary>
+<ul>{{range .Same}}<li>{{.}}</li>{{end}}</ul>
+</details>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,230 @@
+package czimage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+)
+
+// EncodeOptions 新建cz图像的参数
+type EncodeOptions struct {
+	Type      string        // cz0、cz1、cz2、cz3、cz4
+	Colorbits uint16        // 颜色位数，0则cz2为8，其余为32；cz1支持4、8、24、32
+	BlockSize int           // 压缩分块大小，0则使用默认值
+	Jobs      int           // 并行压缩的数量，0为不并行
+	Flag      uint8         // Cz3Header.Flag
+	X         uint16        // Cz3Header.X 显示位置
+	Y         uint16        // Cz3Header.Y 显示位置
+	Width1    uint16        // Cz3Header.Width1，0则使用图像宽度
+	Height1   uint16        // Cz3Header.Heigth1，0则使用图像高度
+	Width2    uint16        // Cz3Header.Width2，0则使用图像宽度
+	Height2   uint16        // Cz3Header.Heigth2，0则使用图像高度
+	Palette   []color.NRGBA // cz1 4/8位调色板，为空时使用 Quantize 生成
+	Dither    bool          // cz1 4/8位时使用Floyd–Steinberg抖动
+}
+
+// Encode 不依赖原cz文件，使用图像创建cz
+//
+//	Description cz0、cz1、cz3、cz4写入与cz3相同的13字节扩展头（位置与尺寸），
+//	  cz2写入3字节扩展头与alpha调色板
+//	Param img image.Image
+//	Param opts EncodeOptions
+//	Return CzImage 使用 Write 输出
+//	Return error
+func Encode(img image.Image, opts EncodeOptions) (CzImage, error) {
+	size := img.Bounds().Size()
+	if size.X <= 0 || size.Y <= 0 || size.X > 0xFFFF || size.Y > 0xFFFF {
+		return nil, fmt.Errorf("invalid image size %dx%d", size.X, size.Y)
+	}
+	pic := ImageToNRGBA(img)
+	if pic.Rect.Min != (image.Point{}) {
+		pic = FillImage(pic, size.X, size.Y)
+	}
+
+	typ := strings.ToLower(opts.Type)
+	colorbits := opts.Colorbits
+	if colorbits == 0 {
+		colorbits = 32
+		if typ == "cz2" {
+			colorbits = 8
+		}
+	}
+	header := CzHeader{
+		Magic:        []byte{'C', 'Z', '0', 0},
+		HeaderLength: 15 + 13,
+		Width:        uint16(size.X),
+		Heigth:       uint16(size.Y),
+		Colorbits:    colorbits,
+	}
+	ext := Cz3Header{
+		Flag:    opts.Flag,
+		X:       opts.X,
+		Y:       opts.Y,
+		Width1:  orDefault(opts.Width1, header.Width),
+		Heigth1: orDefault(opts.Height1, header.Heigth),
+		Width2:  orDefault(opts.Width2, header.Width),
+		Heigth2: orDefault(opts.Height2, header.Heigth),
+	}
+
+	switch typ {
+	case "cz0":
+		if colorbits != 32 {
+			return nil, fmt.Errorf("cz0 supports 32 colorbits, got %d", colorbits)
+		}
+		cz := &Cz0Image{CzHeader: header, Cz0Header: Cz0Header(ext)}
+		cz.PngImage = pic
+		return cz, nil
+	case "cz1":
+		header.Magic[2] = '1'
+		cz := &Cz1Image{CzHeader: header}
+		extended := &bytes.Buffer{}
+		if err := WriteStruct(extended, &ext); err != nil {
+			return nil, err
+		}
+		cz.ExtendedHeader = extended.Bytes()
+		switch colorbits {
+		case 4, 8:
+			count := 1 << colorbits
+			if len(opts.Palette) > count {
+				return nil, fmt.Errorf("palette has %d colors, at most %d allowed", len(opts.Palette), count)
+			}
+			if len(opts.Palette) == 0 {
+				cz.RebuildPalette = true
+			} else {
+				cz.ColorPanel = make([]color.NRGBA, count)
+				copy(cz.ColorPanel, opts.Palette)
+			}
+			cz.Dither = opts.Dither
+		case 24, 32:
+		default:
+			return nil, fmt.Errorf("cz1 supports 4, 8, 24 or 32 colorbits, got %d", colorbits)
+		}
+		cz.BlockSize = opts.BlockSize
+		cz.Jobs = opts.Jobs
+		return cz, cz.importImage(pic, false)
+	case "cz2":
+		if colorbits != 8 {
+			return nil, fmt.Errorf("cz2 supports 8 colorbits, got %d", colorbits)
+		}
+		header.Magic[2] = '2'
+		header.HeaderLength = 15 + 3
+		// cz2只保存alpha，调色板下标即为alpha值
+		cz := &Cz2Image{CzHeader: header, ColorPanel: make([]color.NRGBA, 256)}
+		for i := range cz.ColorPanel {
+			cz.ColorPanel[i] = color.NRGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: uint8(i)}
+		}
+		cz.BlockSize = opts.BlockSize
+		cz.Jobs = opts.Jobs
+		return cz, cz.importImage(pic, false)
+	case "cz3":
+		if colorbits != 32 {
+			return nil, fmt.Errorf("cz3 supports 32 colorbits, got %d", colorbits)
+		}
+		header.Magic[2] = '3'
+		cz := &Cz3Image{CzHeader: header, Cz3Header: ext}
+		cz.BlockSize = opts.BlockSize
+		cz.Jobs = opts.Jobs
+		return cz, cz.importImage(pic)
+	case "cz4":
+		if colorbits != 32 {
+			return nil, fmt.Errorf("cz4 supports 32 colorbits, got %d", colorbits)
+		}
+		header.Magic[2] = '4'
+		cz := &Cz4Image{CzHeader: header, Cz3Header: ext}
+		cz.BlockSize = opts.BlockSize
+		cz.Jobs = opts.Jobs
+		return cz, cz.importImage(pic)
+	}
+	return nil, fmt.Errorf("unknown cz type %q", opts.Type)
+}
+
+// orDefault v为0时返回def
+func orDefault(v, def uint16) uint16 {
+	if v == 0 {
+		return def
+	}
+	return v
+}
+
+// Convert 将cz转换为另一种类型
+//
+//	Description 解码为图像后使用 Encode 重新编码，保留位置、尺寸与Colorblock
+//	Param cz CzImage
+//	Param opts EncodeOptions 位置为0时使用原cz的位置
+//	Return CzImage
+//	Return error
+func Convert(cz CzImage, opts EncodeOptions) (CzImage, error) {
+	m := GetMetadata(cz)
+	if m == nil {
+		return nil, fmt.Errorf("unsupported cz image %T", cz)
+	}
+	if pos := m.Position; pos != nil {
+		if opts.Flag == 0 {
+			opts.Flag = pos.Flag
+		}
+		if opts.X == 0 && opts.Y == 0 {
+			opts.X, opts.Y = pos.X, pos.Y
+		}
+		if opts.Width1 == 0 && opts.Height1 == 0 {
+			opts.Width1, opts.Height1 = pos.Width1, pos.Heigth1
+		}
+		if opts.Width2 == 0 && opts.Height2 == 0 {
+			opts.Width2, opts.Height2 = pos.Width2, pos.Heigth2
+		}
+	}
+	img, err := cz.GetImage()
+	if err != nil {
+		return nil, err
+	}
+	out, err := Encode(img, opts)
+	if err != nil {
+		return nil, err
+	}
+	switch c := out.(type) {
+	case *Cz0Image:
+		c.Colorblock = m.Colorblock
+	case *Cz1Image:
+		c.Colorblock = m.Colorblock
+	case *Cz2Image:
+		c.Colorblock = m.Colorblock
+	case *Cz3Image:
+		c.Colorblock = m.Colorblock
+	case *Cz4Image:
+		c.Colorblock = m.Colorblock
+	}
+	return out, nil
+}
+
+// ConvertWarnings 转换类型时可能导致游戏无法读取或显示异常的原因
+//
+//	Param from string 原类型
+//	Param to string 目标类型
+//	Param hasPosition bool 原cz是否有位置信息
+//	Return []string 为空表示没有已知问题
+func ConvertWarnings(from, to string, hasPosition bool) []string {
+	from, to = strings.ToLower(from), strings.ToLower(to)
+	var warnings []string
+	if from == to {
+		return nil
+	}
+	switch {
+	case from == "cz2":
+		warnings = append(warnings, "CZ2 is the font atlas format, the engine only loads fonts as CZ2")
+	case to == "cz2":
+		warnings = append(warnings, "CZ2 is only used for font atlases and stores alpha only, colors are lost")
+	}
+	switch to {
+	case "cz1":
+		warnings = append(warnings, "CZ1 stores at most 256 colors in 8-bit mode, gradients may band")
+	case "cz4":
+		warnings = append(warnings, "CZ4 is only read by newer engine builds (e.g. Little Busters English), older games cannot load it")
+	case "cz0":
+		warnings = append(warnings, "CZ0 is uncompressed, the file will be much larger")
+	}
+	if !hasPosition && to != "cz2" {
+		warnings = append(warnings, "source has no position header, X/Y are set to 0")
+	}
+	return warnings
+}
@@ -0,0 +1,122 @@
+package czimage
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func testImage(w, h int, alphaOnly bool) *image.NRGBA {
+	pic := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBA{R: uint8(x * 16), G: uint8(y * 16), B: uint8(x ^ y), A: uint8(255 - x*y)}
+			if alphaOnly {
+				c.R, c.G, c.B = 0xFF, 0xFF, 0xFF
+			}
+			pic.SetNRGBA(x, y, c)
+		}
+	}
+	return pic
+}
+
+// czPixels 解码cz，失败时终止测试
+func czPixels(t *testing.T, cz CzImage) *image.NRGBA {
+	t.Helper()
+	img, err := cz.GetImage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ImageToNRGBA(img)
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		typ       string
+		colorbits uint16
+		alphaOnly bool
+	}{
+		{"cz0", 0, false},
+		{"cz1", 32, false},
+		{"cz1", 8, false},
+		{"cz2", 0, true},
+		{"cz3", 0, false},
+		{"cz4", 0, false},
+	}
+	for _, tt := range tests {
+		src := testImage(13, 7, tt.alphaOnly)
+		cz, err := Encode(src, EncodeOptions{Type: tt.typ, Colorbits: tt.colorbits, X: 100, Y: 200, BlockSize: 16})
+		if err != nil {
+			t.Fatalf("%s/%d: %v", tt.typ, tt.colorbits, err)
+		}
+		buf := &bytes.Buffer{}
+		if err = cz.Write(buf); err != nil {
+			t.Fatalf("%s/%d: Write: %v", tt.typ, tt.colorbits, err)
+		}
+		loaded := LoadCzImage(buf.Bytes())
+		if loaded == nil {
+			t.Fatalf("%s/%d: LoadCzImage failed", tt.typ, tt.colorbits)
+		}
+		got := czPixels(t, loaded)
+		if !bytes.Equal(got.Pix, src.Pix) {
+			t.Errorf("%s/%d: pixels differ", tt.typ, tt.colorbits)
+		}
+		switch c := loaded.(type) {
+		case *Cz3Image:
+			if c.X != 100 || c.Y != 200 || c.Width1 != 13 || c.Heigth1 != 7 {
+				t.Errorf("cz3 header = %+v", c.Cz3Header)
+			}
+		case *Cz0Image:
+			if c.X != 100 || c.Y != 200 {
+				t.Errorf("cz0 header = %+v", c.Cz0Header)
+			}
+		}
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	if _, err := Encode(testImage(4, 4, false), EncodeOptions{Type: "cz3", Colorbits: 24}); err == nil {
+		t.Error("expected colorbits error")
+	}
+	if _, err := Encode(testImage(4, 4, false), EncodeOptions{Type: "cz1", Colorbits: 4, Palette: make([]color.NRGBA, 17)}); err == nil {
+		t.Error("expected palette size error")
+	}
+	if _, err := Encode(testImage(4, 4, false), EncodeOptions{Type: "cz9"}); err == nil {
+		t.Error("expected unknown type error")
+	}
+}
+
+func TestConvert(t *testing.T) {
+	src := testImage(8, 6, false)
+	cz, err := Encode(src, EncodeOptions{Type: "cz1", X: 7, Y: 9, Width1: 100, Height1: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err = cz.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	out, err := Convert(LoadCzImage(buf.Bytes()), EncodeOptions{Type: "cz3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err = out.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	c, ok := LoadCzImage(buf.Bytes()).(*Cz3Image)
+	if !ok || c.X != 7 || c.Y != 9 || c.Width1 != 100 || c.Heigth1 != 50 {
+		t.Fatalf("converted = %+v", out)
+	}
+	if !bytes.Equal(czPixels(t, c).Pix, src.Pix) {
+		t.Error("pixels differ")
+	}
+
+	if w := ConvertWarnings("cz1", "cz3", true); len(w) != 0 {
+		t.Errorf("unexpected warnings %v", w)
+	}
+	if w := ConvertWarnings("cz2", "cz3", true); len(w) == 0 {
+		t.Error("expected font warning")
+	}
+}
@@ -0,0 +1,105 @@
+package czimage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-restruct/restruct"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+// 注册cz0-cz4，使 image.Decode 与 image.DecodeConfig 可以直接读取cz文件
+func init() {
+	for _, magic := range []string{"CZ0", "CZ1", "CZ2", "CZ3", "CZ4"} {
+		image.RegisterFormat("cz"+magic[2:], magic, Decode, DecodeConfig)
+	}
+}
+
+// Decode 读取cz文件并解码为图像
+//
+//	Description 无效的数据返回错误，解码过程中的panic同样作为错误返回
+//	Param r io.Reader
+//	Return img image.Image
+//	Return err error
+func Decode(r io.Reader) (img image.Image, err error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			img, err = nil, fmt.Errorf("cz: %v", e)
+		}
+	}()
+	cz, err := ReadCzImage(data)
+	if err != nil {
+		return nil, fmt.Errorf("cz: %v", err)
+	}
+	if img, err = cz.GetImage(); err != nil {
+		return nil, fmt.Errorf("cz: %v", err)
+	}
+	return img, nil
+}
+
+// DecodeConfig 只读取cz文件头，返回图像尺寸
+//
+//	Description 解码结果均为 image.NRGBA
+//	Param r io.Reader
+//	Return image.Config
+//	Return error
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	data := make([]byte, 15)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return image.Config{}, err
+	}
+	header := CzHeader{}
+	if err := restruct.Unpack(data, binary.LittleEndian, &header); err != nil {
+		return image.Config{}, err
+	}
+	if string(header.Magic[:2]) != "CZ" {
+		return image.Config{}, errors.New("cz: invalid magic")
+	}
+	return image.Config{
+		ColorModel: color.NRGBAModel,
+		Width:      int(header.Width),
+		Height:     int(header.Heigth),
+	}, nil
+}
+
+// FormatFromExt 根据文件扩展名判断导出格式，无法判断时为png
+func FormatFromExt(file string) string {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".bmp":
+		return "bmp"
+	case ".tif", ".tiff":
+		return "tiff"
+	}
+	return "png"
+}
+
+// EncodeFormat 将图像编码为png、bmp或tiff
+//
+//	Description tiff使用Deflate压缩，保留alpha通道
+//	Param w io.Writer
+//	Param img image.Image
+//	Param format string png、bmp、tiff
+//	Return error
+func EncodeFormat(w io.Writer, img image.Image, format string) error {
+	switch strings.ToLower(format) {
+	case "", "png":
+		return png.Encode(w, img)
+	case "bmp":
+		return bmp.Encode(w, img)
+	case "tif", "tiff":
+		return tiff.Encode(w, img, &tiff.Options{Compression: tiff.Deflate})
+	}
+	return fmt.Errorf("unsupported image format %q", format)
+}
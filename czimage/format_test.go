@@ -0,0 +1,61 @@
+package czimage
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+func TestImageDecode(t *testing.T) {
+	src := testImage(9, 5, false)
+	for _, typ := range []string{"cz0", "cz1", "cz3", "cz4"} {
+		cz, err := Encode(src, EncodeOptions{Type: typ})
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := &bytes.Buffer{}
+		if err = cz.Write(buf); err != nil {
+			t.Fatal(err)
+		}
+		config, format, err := image.DecodeConfig(bytes.NewReader(buf.Bytes()))
+		if err != nil || format != typ || config.Width != 9 || config.Height != 5 {
+			t.Fatalf("%s: DecodeConfig = %+v, %q, %v", typ, config, format, err)
+		}
+		img, format, err := image.Decode(bytes.NewReader(buf.Bytes()))
+		if err != nil || format != typ {
+			t.Fatalf("%s: Decode = %q, %v", typ, format, err)
+		}
+		if !bytes.Equal(ImageToNRGBA(img).Pix, src.Pix) {
+			t.Errorf("%s: pixels differ", typ)
+		}
+	}
+	if _, _, err := image.Decode(bytes.NewReader([]byte("CZ3\x00truncated"))); err == nil {
+		t.Error("expected error for truncated cz")
+	}
+}
+
+func TestEncodeFormat(t *testing.T) {
+	src := testImage(9, 5, false)
+	for format, decode := range map[string]func(*bytes.Reader) (image.Image, error){
+		"bmp":  func(r *bytes.Reader) (image.Image, error) { return bmp.Decode(r) },
+		"tiff": func(r *bytes.Reader) (image.Image, error) { return tiff.Decode(r) },
+	} {
+		buf := &bytes.Buffer{}
+		if err := EncodeFormat(buf, src, format); err != nil {
+			t.Fatal(err)
+		}
+		img, err := decode(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if img.Bounds() != src.Bounds() {
+			t.Errorf("%s: bounds %v", format, img.Bounds())
+		}
+	}
+	if FormatFromExt("a.TIF") != "tiff" || FormatFromExt("a.bmp") != "bmp" || FormatFromExt("a") != "png" {
+		t.Error("FormatFromExt")
+	}
+}
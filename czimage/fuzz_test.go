@@ -0,0 +1,178 @@
+package czimage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// fuzzCzSeeds 各类型的小cz文件
+func fuzzCzSeeds(f *testing.F) {
+	for _, tt := range []struct {
+		typ       string
+		colorbits uint16
+		alphaOnly bool
+	}{
+		{"cz0", 0, false},
+		{"cz1", 8, false},
+		{"cz1", 32, false},
+		{"cz2", 0, true},
+		{"cz3", 0, false},
+		{"cz4", 0, false},
+	} {
+		cz, err := Encode(testImage(5, 4, tt.alphaOnly), EncodeOptions{Type: tt.typ, Colorbits: tt.colorbits})
+		if err != nil {
+			f.Fatal(err)
+		}
+		buf := &bytes.Buffer{}
+		if err = cz.Write(buf); err != nil {
+			f.Fatal(err)
+		}
+		f.Add(buf.Bytes())
+	}
+}
+
+func FuzzLoadCzImage(f *testing.F) {
+	fuzzCzSeeds(f)
+	f.Add([]byte("CZ3\x00\x1c\x00\x00\x00\xff\xff\xff\xff\x20\x00\x00"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cz := LoadCzImage(data)
+		if cz == nil {
+			return
+		}
+		img, err := cz.GetImage()
+		if err != nil {
+			return
+		}
+		if img == nil {
+			t.Fatal("GetImage returned neither an image nor an error")
+		}
+		// png不支持0宽高的图像，只要求不panic
+		_ = cz.Export(&bytes.Buffer{})
+	})
+}
+
+func FuzzDecompressLZW(f *testing.F) {
+	_, codes := compressLZWBlock([]byte("abababababcabcabcabc"), 0)
+	seed := make([]byte, len(codes)*2)
+	for i, c := range codes {
+		binary.LittleEndian.PutUint16(seed[i*2:], c)
+	}
+	f.Add(seed, 20)
+	f.Add([]byte{0x01, 0x01, 0x01, 0x01}, 0)
+	f.Fuzz(func(t *testing.T, data []byte, size int) {
+		if size < 0 || size > 1<<20 {
+			return
+		}
+		compressed := make([]uint16, len(data)/2)
+		for i := range compressed {
+			compressed[i] = binary.LittleEndian.Uint16(data[i*2:])
+		}
+		out, err := decompressLZW(compressed, size)
+		if err == nil && len(out) > size+maxLZWOverrun {
+			t.Fatalf("%d bytes decompressed, limit %d", len(out), size+maxLZWOverrun)
+		}
+	})
+}
+
+func FuzzDecompressLZW2(f *testing.F) {
+	_, seed := compressLZW2Block([]byte("abababababcabcabcabc"), 0)
+	f.Add(seed, 20)
+	f.Add([]byte{0xFF, 0xFF, 0xFF}, 0)
+	f.Fuzz(func(t *testing.T, data []byte, size int) {
+		if size < 0 || size > 1<<20 {
+			return
+		}
+		out, err := decompressLZW2(data, size)
+		if err == nil && len(out) > size+maxLZWOverrun {
+			t.Fatalf("%d bytes decompressed, limit %d", len(out), size+maxLZWOverrun)
+		}
+	})
+}
+
+// fuzzHeader 限制尺寸，避免生成过大的图像
+func fuzzHeader(width, height uint8, colorbits uint16) *CzHeader {
+	return &CzHeader{Width: uint16(width), Heigth: uint16(height), Colorbits: colorbits}
+}
+
+func FuzzLineDiff(f *testing.F) {
+	f.Add(uint8(3), uint8(4), uint16(32), make([]byte, 3*4*4))
+	f.Add(uint8(2), uint8(2), uint16(24), make([]byte, 2*2*3))
+	f.Add(uint8(9), uint8(1), uint16(32), []byte{1, 2, 3})
+	f.Fuzz(func(t *testing.T, width, height uint8, colorbits uint16, data []byte) {
+		header := fuzzHeader(width, height, colorbits)
+		img, err := LineDiff(header, data)
+		if err == nil && img.Bounds().Dx() != int(width) {
+			t.Fatalf("width %d, want %d", img.Bounds().Dx(), width)
+		}
+	})
+}
+
+func FuzzLineDiff4(f *testing.F) {
+	f.Add(uint8(3), uint8(4), make([]byte, 3*4*4))
+	f.Add(uint8(7), uint8(7), []byte{1, 2, 3})
+	f.Fuzz(func(t *testing.T, width, height uint8, data []byte) {
+		header := fuzzHeader(width, height, 32)
+		img, err := LineDiff4(header, data)
+		if err == nil && img.Bounds().Dy() != int(height) {
+			t.Fatalf("height %d, want %d", img.Bounds().Dy(), height)
+		}
+	})
+}
+
+func TestLoadCzImageMalformed(t *testing.T) {
+	valid := &bytes.Buffer{}
+	cz, err := Encode(testImage(5, 4, false), EncodeOptions{Type: "cz3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cz.Write(valid); err != nil {
+		t.Fatal(err)
+	}
+	data := valid.Bytes()
+
+	withBlockCount := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint32(withBlockCount[28:], 0xFFFFFFFF)
+	huge := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint16(huge[8:], 0xFFFF)
+	binary.LittleEndian.PutUint16(huge[10:], 0xFFFF)
+	badHeaderLength := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint32(badHeaderLength[4:], 0xFFFFFF)
+	badColorbits := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint16(badColorbits[12:], 0)
+	// 宽高在限制内，但分块信息中的数据不足
+	larger := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint16(larger[8:], 1000)
+
+	for name, data := range map[string][]byte{
+		"short":         data[:10],
+		"block count":   withBlockCount,
+		"image size":    huge,
+		"header length": badHeaderLength,
+		"truncated":     data[:32],
+		"colorbits":     badColorbits,
+		"raw size":      larger,
+	} {
+		if _, err := ReadCzImage(data); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	// 分块信息完整但压缩数据不足，在解码时报错
+	truncated, err := ReadCzImage(data[:len(data)-4])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = truncated.GetImage(); err == nil {
+		t.Error("truncated data: expected a decode error")
+	}
+
+	// 导入的png无效时返回错误
+	cz1, err := Encode(testImage(5, 4, false), EncodeOptions{Type: "cz1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cz1.Import(bytes.NewReader([]byte("not a png")), false); err == nil {
+		t.Error("invalid png: expected an import error")
+	}
+}
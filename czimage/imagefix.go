@@ -1,6 +1,7 @@
 package czimage
 
 import (
+	"fmt"
 	"github.com/golang/glog"
 	"image"
 	"image/color"
@@ -84,11 +85,19 @@ func DiffLine(header CzHeader, pic *image.NRGBA) (data []byte) {
 //  Param header *CzHeader
 //  Param data []byte
 //  Return image.Image
+//  Return error 颜色位数不支持或数据不足
 //
-func LineDiff(header *CzHeader, data []byte) image.Image {
+func LineDiff(header *CzHeader, data []byte) (image.Image, error) {
 	//os.WriteFile("../data/LB_EN/IMAGE/ld.data", data, 0666)
 	width := int(header.Width)
 	height := int(header.Heigth)
+	pixelByteCount := int(header.Colorbits >> 3)
+	if pixelByteCount != 3 && pixelByteCount != 4 {
+		return nil, fmt.Errorf("LineDiff: unsupported colorbits %d", header.Colorbits)
+	}
+	if need := width * height * pixelByteCount; len(data) < need {
+		return nil, fmt.Errorf("LineDiff: %d bytes of pixel data, need %d", len(data), need)
+	}
 	pic := image.NewNRGBA(image.Rect(0, 0, width, height))
 	
 	// PATCH YOREMI: blockHeight calculation (must match DiffLine)
@@ -96,7 +105,6 @@ func LineDiff(header *CzHeader, data []byte) image.Image {
 	
 	glog.V(0).Infof("LineDiff: height=%d, colorblock=%d, blockHeight=%d\n", height, header.Colorblock, blockHeight)
 	
-	pixelByteCount := int(header.Colorbits >> 3)
 	lineByteCount := width * pixelByteCount
 	var currLine []byte
 	preLine := make([]byte, lineByteCount)
@@ -124,7 +132,7 @@ func LineDiff(header *CzHeader, data []byte) image.Image {
 	}
 	
 	//os.WriteFile("../data/LB_EN/IMAGE/ld.data.pix", pic.Pix, 0666)
-	return pic
+	return pic, nil
 }
 
 // DiffLine4 CZ4 encode: NRGBA pixels → separated [RGB w*h*3][Alpha w*h] with delta encoding
@@ -200,10 +208,14 @@ func DiffLine4(header CzHeader, pic *image.NRGBA) []byte {
 //  Param header *CzHeader
 //  Param data []byte
 //  Return image.Image
+//  Return error 数据不足
 //
-func LineDiff4(header *CzHeader, data []byte) image.Image {
+func LineDiff4(header *CzHeader, data []byte) (image.Image, error) {
 	width := int(header.Width)
 	height := int(header.Heigth)
+	if need := width * height * 4; len(data) < need {
+		return nil, fmt.Errorf("LineDiff4: %d bytes of pixel data, need %d", len(data), need)
+	}
 	pic := image.NewNRGBA(image.Rect(0, 0, width, height))
 
 	blockHeight := (height + 2) / 3
@@ -254,5 +266,5 @@ func LineDiff4(header *CzHeader, data []byte) image.Image {
 	glog.V(0).Infof("LineDiff4: %dx%d, blockHeight=%d, decoded from %d bytes\n",
 		width, height, blockHeight, len(data))
 
-	return pic
+	return pic, nil
 }
@@ -60,13 +60,38 @@ func compressLZW(data []byte, size int, last string) (count int, compressed []ui
 	return count, compressed, lastElement
 }
 
+// maxLZWOverrun 一块解压后允许超过RawSize的字节数
+//
+//	Description 旧版 Compress 会把一个element留给下一块，解压结果可能略大于RawSize。
+//	  超过该值视为损坏的数据，防止恶意的编码序列占用过多内存
+const maxLZWOverrun = 0x10000
+
+// capacityHint 解压缓冲区的初始容量，不完全信任文件中的RawSize
+func capacityHint(size, compressedBytes int) int {
+	if max := compressedBytes * 64; size > max {
+		return max
+	}
+	if size < 0 {
+		return 0
+	}
+	return size
+}
+
 // decompressLZW lzw解压
 //
 //	Description lzw解压一块
 //	Param compressed []uint16 压缩的数据
-//	Param size int 未压缩数据大小，可超过
+//	Param size int 未压缩数据大小，可超过 maxLZWOverrun 以内
 //	Return []byte 解压后的数据
-func decompressLZW(compressed []uint16, size int) []byte {
+//	Return error 编码无效或解压结果过大
+func decompressLZW(compressed []uint16, size int) ([]byte, error) {
+	if len(compressed) == 0 {
+		return nil, nil
+	}
+	if compressed[0] > 0xFF {
+		return nil, fmt.Errorf("bad first compressed element: %d", compressed[0])
+	}
+	limit := size + maxLZWOverrun
 
 	dictionary := make(map[uint16][]byte)
 	for i := 0; i < 256; i++ {
@@ -74,7 +99,7 @@ func decompressLZW(compressed []uint16, size int) []byte {
 	}
 	dictionaryCount := uint16(len(dictionary))
 	w := dictionary[compressed[0]]
-	decompressed := make([]byte, 0, size)
+	decompressed := make([]byte, 0, capacityHint(size, len(compressed)*2))
 	for _, element := range compressed {
 		var entry []byte
 		if x, ok := dictionary[element]; ok {
@@ -85,7 +110,10 @@ func decompressLZW(compressed []uint16, size int) []byte {
 			copy(entry, w)
 			entry = append(entry, w[0])
 		} else {
-			panic(fmt.Sprintf("Bad compressed element: %d", element))
+			return nil, fmt.Errorf("bad compressed element: %d", element)
+		}
+		if len(decompressed)+len(entry) > limit {
+			return nil, fmt.Errorf("decompressed block exceeds %d bytes", size)
 		}
 		decompressed = append(decompressed, entry...)
 		w = append(w, entry[0])
@@ -94,20 +122,28 @@ func decompressLZW(compressed []uint16, size int) []byte {
 
 		w = entry
 	}
-	return decompressed
+	return decompressed, nil
 }
 
-func decompressLZW2(data []byte, size int) []byte {
+// decompressLZW2 lzw解压 CZ2专用
+//
+//	Description lzw解压一块，编码为15/18位的位流
+//	Param data []byte 压缩的数据
+//	Param size int 未压缩数据大小，可超过 maxLZWOverrun 以内
+//	Return []byte 解压后的数据
+//	Return error 编码无效或解压结果过大
+func decompressLZW2(data []byte, size int) ([]byte, error) {
 	dictionary := make(map[int][]byte)
 	for i := 0; i < 256; i++ {
 		dictionary[i] = []byte{byte(i)}
 	}
 	dictionaryCount := len(dictionary)
-	result := make([]byte, 0, size)
+	limit := size + maxLZWOverrun
+	result := make([]byte, 0, capacityHint(size, len(data)))
 
 	dataSize := len(data)
 	// Yoremi Patch: copy into a fresh buffer with explicit capacity before
-	// appending the 2 padding bytes. The original code did
+	// appending the padding bytes. The original code did
 	//   data = append(data, []byte{0, 0}...)
 	// which writes into data's underlying array when cap(data) > len(data).
 	// In Decompress2, `data` is `parent[offsetTemp:offset]`, a sub-slice
@@ -116,7 +152,9 @@ func decompressLZW2(data []byte, size int) []byte {
 	// block's bitstream. That corruption breaks the LZW state of every
 	// block after the first and can leave the decoded output shorter than
 	// W*H, triggering an index-out-of-range panic in Cz2Image.decompress.
-	padded := make([]byte, dataSize+2, dataSize+2)
+	//
+	// 一个编码最多19位，从数据末尾之后的字节读取时最多越过4字节
+	padded := make([]byte, dataSize+4)
 	copy(padded, data)
 	bitIO := NewBitIO(padded)
 	w := dictionary[0]
@@ -139,7 +177,10 @@ func decompressLZW2(data []byte, size int) []byte {
 		} else if element == dictionaryCount {
 			entry = append(w, w[0])
 		} else {
-			panic(fmt.Sprintf("Bad compressed element: %d", element))
+			return nil, fmt.Errorf("bad compressed element: %d", element)
+		}
+		if len(result)+len(entry) > limit {
+			return nil, fmt.Errorf("decompressed block exceeds %d bytes", size)
 		}
 		result = append(result, entry...)
 		w = append(w, entry[0])
@@ -147,7 +188,7 @@ func decompressLZW2(data []byte, size int) []byte {
 		dictionaryCount++
 		w = entry
 	}
-	return result
+	return result, nil
 }
 
 func compressLZW2(data []byte, size int, last string) (count int, compressed []byte, lastElement string) {
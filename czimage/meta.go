@@ -0,0 +1,170 @@
+package czimage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/go-restruct/restruct"
+)
+
+// extHeaderSize Cz3Header 结构体大小
+const extHeaderSize = 13
+
+// Position cz0、cz1、cz3、cz4扩展头中的位置与尺寸，与 Cz3Header 相同
+type Position struct {
+	Flag    uint8  `json:"flag"`
+	X       uint16 `json:"x"`
+	Y       uint16 `json:"y"`
+	Width1  uint16 `json:"width1"`
+	Heigth1 uint16 `json:"height1"`
+	Width2  uint16 `json:"width2"`
+	Heigth2 uint16 `json:"height2"`
+}
+
+// Metadata cz文件头信息，导出为png时作为json附带文件
+//
+//	Description Width、Height、Colorbits、HeaderLength 仅供参考，导入时由图像与Extra决定
+type Metadata struct {
+	Type         string    `json:"type"` // cz0-cz4
+	Width        uint16    `json:"width"`
+	Height       uint16    `json:"height"`
+	Colorbits    uint16    `json:"colorbits"`
+	Colorblock   uint8     `json:"colorblock"`
+	HeaderLength uint32    `json:"header_length"`
+	Position     *Position `json:"position,omitempty"` // cz2没有位置信息
+	Extra        string    `json:"extra,omitempty"`    // 扩展头之后、HeaderLength之前未解析数据的hex，cz2为3字节扩展头
+}
+
+// MetaFile 图像文件对应的json附带文件名
+func MetaFile(file string) string {
+	return file + ".json"
+}
+
+// GetMetadata 读取cz文件头信息
+//
+//	Param cz CzImage
+//	Return *Metadata
+func GetMetadata(cz CzImage) *Metadata {
+	m := &Metadata{}
+	var header *CzHeader
+	switch c := cz.(type) {
+	case *Cz0Image:
+		header = &c.CzHeader
+		pos := Position(c.Cz0Header)
+		m.Position = &pos
+		m.Extra = hex.EncodeToString(c.ExtraHeader)
+	case *Cz1Image:
+		header = &c.CzHeader
+		// cz1扩展头的前13字节与 Cz3Header 相同
+		if len(c.ExtendedHeader) >= extHeaderSize {
+			pos := Position{}
+			if err := restruct.Unpack(c.ExtendedHeader, binary.LittleEndian, &pos); err == nil {
+				m.Position = &pos
+			}
+			m.Extra = hex.EncodeToString(c.ExtendedHeader[extHeaderSize:])
+		} else {
+			m.Extra = hex.EncodeToString(c.ExtendedHeader)
+		}
+	case *Cz2Image:
+		header = &c.CzHeader
+		m.Extra = hex.EncodeToString([]byte{c.Unknown1, c.Unknown2, c.Unknown3})
+	case *Cz3Image:
+		header = &c.CzHeader
+		pos := Position(c.Cz3Header)
+		m.Position = &pos
+	case *Cz4Image:
+		header = &c.CzHeader
+		pos := Position(c.Cz3Header)
+		m.Position = &pos
+	default:
+		return nil
+	}
+	m.Type = strings.ToLower(string(header.Magic[:3]))
+	m.Width = header.Width
+	m.Height = header.Heigth
+	m.Colorbits = header.Colorbits
+	m.Colorblock = header.Colorblock
+	m.HeaderLength = header.HeaderLength
+	return m
+}
+
+// ApplyMetadata 使用头信息修改cz
+//
+//	Description 修改Colorblock、位置与Extra，cz0、cz1的HeaderLength随Extra长度变化。
+//	  在 Import 之后、Write 之前调用
+//	Param cz CzImage
+//	Param m *Metadata
+//	Return error
+func ApplyMetadata(cz CzImage, m *Metadata) error {
+	if cur := GetMetadata(cz); cur == nil || !strings.EqualFold(m.Type, cur.Type) {
+		return fmt.Errorf("metadata type %s does not match the cz image", m.Type)
+	}
+	extra, err := hex.DecodeString(m.Extra)
+	if err != nil {
+		return fmt.Errorf("metadata extra: %v", err)
+	}
+	switch c := cz.(type) {
+	case *Cz0Image:
+		if m.Position != nil {
+			c.Cz0Header = Cz0Header(*m.Position)
+		}
+		c.ExtraHeader = extra
+		c.HeaderLength = 15 + extHeaderSize + uint32(len(extra))
+		c.Colorblock = m.Colorblock
+	case *Cz1Image:
+		if m.Position != nil {
+			buf := &bytes.Buffer{}
+			if err = WriteStruct(buf, m.Position); err != nil {
+				return err
+			}
+			extra = append(buf.Bytes(), extra...)
+		}
+		c.ExtendedHeader = extra
+		c.HeaderLength = 15 + uint32(len(extra))
+		c.Colorblock = m.Colorblock
+	case *Cz2Image:
+		if len(extra) != 3 {
+			return fmt.Errorf("metadata extra: cz2 needs 3 bytes, got %d", len(extra))
+		}
+		c.Cz2Header = Cz2Header{Unknown1: extra[0], Unknown2: extra[1], Unknown3: extra[2]}
+		c.Colorblock = m.Colorblock
+	case *Cz3Image:
+		if m.Position != nil {
+			c.Cz3Header = Cz3Header(*m.Position)
+		}
+		c.Colorblock = m.Colorblock
+	case *Cz4Image:
+		if m.Position != nil {
+			c.Cz3Header = Cz3Header(*m.Position)
+		}
+		c.Colorblock = m.Colorblock
+	}
+	return nil
+}
+
+// LoadMetadata 读取json附带文件
+func LoadMetadata(file string) (*Metadata, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	m := &Metadata{}
+	if err = json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return m, nil
+}
+
+// Save 写入json附带文件
+func (m *Metadata) Save(file string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0666)
+}
@@ -0,0 +1,52 @@
+package czimage
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func TestMetadataRoundTrip(t *testing.T) {
+	src := testImage(6, 4, false)
+	for _, typ := range []string{"cz0", "cz1", "cz3"} {
+		cz, err := Encode(src, EncodeOptions{Type: typ, X: 10, Y: 20})
+		if err != nil {
+			t.Fatal(err)
+		}
+		file := filepath.Join(t.TempDir(), "a.png.json")
+		if err = GetMetadata(cz).Save(file); err != nil {
+			t.Fatal(err)
+		}
+		m, err := LoadMetadata(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Type != typ || m.Position == nil || m.Position.X != 10 || m.Position.Y != 20 {
+			t.Fatalf("%s: metadata = %+v", typ, m)
+		}
+		m.Position.X, m.Position.Y = 30, 40
+		if typ != "cz3" {
+			m.Extra = "aabbcc"
+		}
+		if err = ApplyMetadata(cz, m); err != nil {
+			t.Fatal(err)
+		}
+		buf := &bytes.Buffer{}
+		if err = cz.Write(buf); err != nil {
+			t.Fatal(err)
+		}
+		loaded := LoadCzImage(buf.Bytes())
+		got := GetMetadata(loaded)
+		if got.Position.X != 30 || got.Position.Y != 40 || got.Extra != m.Extra {
+			t.Errorf("%s: reloaded metadata = %+v %+v", typ, got, got.Position)
+		}
+		if !bytes.Equal(czPixels(t, loaded).Pix, src.Pix) {
+			t.Errorf("%s: pixels differ", typ)
+		}
+	}
+
+	cz, _ := Encode(src, EncodeOptions{Type: "cz3"})
+	if err := ApplyMetadata(cz, &Metadata{Type: "cz4"}); err == nil {
+		t.Error("expected type mismatch error")
+	}
+}
@@ -0,0 +1,235 @@
+package czimage
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"path"
+)
+
+// oraMimetype OpenRaster的mimetype，必须为zip中第一个不压缩的文件
+const oraMimetype = "image/openraster"
+
+// Layer OpenRaster文档中的一层
+type Layer struct {
+	Name   string       // 图层名，compose时为cz文件名
+	X, Y   int          // 在画布中的位置
+	Image  *image.NRGBA // 图层图像，Rect从(0,0)开始
+	Hidden bool
+}
+
+// Bounds 图层在画布中的范围
+func (l *Layer) Bounds() image.Rectangle {
+	return image.Rectangle{Max: l.Image.Rect.Size()}.Add(image.Pt(l.X, l.Y))
+}
+
+// CzLayer 将cz作为图层，按文件头中的X/Y放置
+//
+//	Description cz2没有位置信息，放在(0,0)
+//	Param name string 图层名
+//	Param cz CzImage
+//	Return *Layer
+//	Return error
+func CzLayer(name string, cz CzImage) (*Layer, error) {
+	img, err := cz.GetImage()
+	if err != nil {
+		return nil, err
+	}
+	layer := &Layer{Name: name, Image: layerImage(img)}
+	layer.X, layer.Y = czPosition(GetMetadata(cz))
+	return layer, nil
+}
+
+// czPosition cz的显示位置，没有位置信息时为(0,0)
+func czPosition(m *Metadata) (x, y int) {
+	if m.Position == nil {
+		return 0, 0
+	}
+	return int(m.Position.X), int(m.Position.Y)
+}
+
+// layerImage 复制为从(0,0)开始的NRGBA
+func layerImage(img image.Image) *image.NRGBA {
+	pic := image.NewNRGBA(image.Rectangle{Max: img.Bounds().Size()})
+	draw.Draw(pic, pic.Rect, img, img.Bounds().Min, draw.Src)
+	return pic
+}
+
+// oraStack stack.xml
+type oraStack struct {
+	XMLName xml.Name   `xml:"image"`
+	Version string     `xml:"version,attr"`
+	Width   int        `xml:"w,attr"`
+	Height  int        `xml:"h,attr"`
+	Layers  []oraLayer `xml:"stack>layer"`
+}
+
+type oraLayer struct {
+	Name       string  `xml:"name,attr"`
+	Src        string  `xml:"src,attr"`
+	X          int     `xml:"x,attr"`
+	Y          int     `xml:"y,attr"`
+	Opacity    float64 `xml:"opacity,attr"`
+	Visibility string  `xml:"visibility,attr"`
+}
+
+// WriteORA 写出OpenRaster文档
+//
+//	Description layers从下到上排列，画布为包含全部图层的最小范围（从(0,0)开始），
+//	  因此图层坐标与cz中的X/Y相同。同时写出合成图与缩略图
+//	Param w io.Writer
+//	Param layers []*Layer
+//	Return error
+func WriteORA(w io.Writer, layers []*Layer) error {
+	if len(layers) == 0 {
+		return fmt.Errorf("no layers")
+	}
+	var canvas image.Rectangle
+	for _, l := range layers {
+		canvas = canvas.Union(l.Bounds())
+	}
+	stack := oraStack{Version: "0.0.3", Width: canvas.Max.X, Height: canvas.Max.Y}
+	merged := image.NewNRGBA(image.Rect(0, 0, canvas.Max.X, canvas.Max.Y))
+	for _, l := range layers {
+		if !l.Hidden {
+			draw.Draw(merged, l.Bounds(), l.Image, image.Point{}, draw.Over)
+		}
+	}
+
+	zw := zip.NewWriter(w)
+	mt, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err = io.WriteString(mt, oraMimetype); err != nil {
+		return err
+	}
+	writePng := func(name string, img image.Image) error {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		return png.Encode(f, img)
+	}
+	// stack.xml中第一层在最上面
+	for i := len(layers) - 1; i >= 0; i-- {
+		l := layers[i]
+		src := fmt.Sprintf("data/layer%03d.png", i)
+		if err = writePng(src, l.Image); err != nil {
+			return err
+		}
+		visibility := "visible"
+		if l.Hidden {
+			visibility = "hidden"
+		}
+		stack.Layers = append(stack.Layers, oraLayer{Name: l.Name, Src: src, X: l.X, Y: l.Y, Opacity: 1, Visibility: visibility})
+	}
+	f, err := zw.Create("stack.xml")
+	if err != nil {
+		return err
+	}
+	if _, err = io.WriteString(f, xml.Header); err != nil {
+		return err
+	}
+	if err = xml.NewEncoder(f).Encode(stack); err != nil {
+		return err
+	}
+	if err = writePng("mergedimage.png", merged); err != nil {
+		return err
+	}
+	if err = writePng("Thumbnails/thumbnail.png", Thumbnail(merged, 256)); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// ReadORA 读取OpenRaster文档
+//
+//	Description 返回的图层从下到上排列，忽略嵌套的stack
+//	Param r io.ReaderAt
+//	Param size int64
+//	Return []*Layer
+//	Return error
+func ReadORA(r io.ReaderAt, size int64) ([]*Layer, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	read := func(name string) ([]byte, error) {
+		f, err := zr.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return io.ReadAll(io.LimitReader(f, maxRawSize+1))
+	}
+	if data, err := read("mimetype"); err != nil || string(bytes.TrimSpace(data)) != oraMimetype {
+		return nil, fmt.Errorf("not an OpenRaster document")
+	}
+	data, err := read("stack.xml")
+	if err != nil {
+		return nil, err
+	}
+	stack := oraStack{}
+	if err = xml.Unmarshal(data, &stack); err != nil {
+		return nil, fmt.Errorf("stack.xml: %w", err)
+	}
+	layers := make([]*Layer, len(stack.Layers))
+	for i, l := range stack.Layers {
+		data, err := read(path.Clean(l.Src))
+		if err != nil {
+			return nil, fmt.Errorf("layer %q: %w", l.Name, err)
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("layer %q: %w", l.Name, err)
+		}
+		layers[len(layers)-1-i] = &Layer{Name: l.Name, X: l.X, Y: l.Y, Image: layerImage(img), Hidden: l.Visibility == "hidden"}
+	}
+	return layers, nil
+}
+
+// SplitLayer 将图层放回cz的范围
+//
+//	Description 图层可能被移动或被编辑软件裁剪为内容范围，
+//	  按画布坐标取出cz所在矩形的像素，矩形外的不透明像素被丢弃
+//	Param l *Layer
+//	Param rect image.Rectangle cz在画布中的范围
+//	Return pic *image.NRGBA 与cz大小相同的图像
+//	Return clipped int 被丢弃的不透明像素数
+func SplitLayer(l *Layer, rect image.Rectangle) (pic *image.NRGBA, clipped int) {
+	pic = image.NewNRGBA(image.Rectangle{Max: rect.Size()})
+	bounds := l.Bounds()
+	draw.Draw(pic, bounds.Sub(rect.Min), l.Image, image.Point{}, draw.Src)
+	for y := 0; y < l.Image.Rect.Dy(); y++ {
+		for x := 0; x < l.Image.Rect.Dx(); x++ {
+			if !image.Pt(bounds.Min.X+x, bounds.Min.Y+y).In(rect) && l.Image.Pix[y*l.Image.Stride+x*4+3] != 0 {
+				clipped++
+			}
+		}
+	}
+	return pic, clipped
+}
+
+// ImportLayer 将编辑后的图层导入cz
+//
+//	Description cz的宽高与位置保持不变，之后调用 Write 保存
+//	Param cz CzImage 原cz
+//	Param l *Layer
+//	Return clipped int 超出cz范围被丢弃的不透明像素数
+//	Return err error
+func ImportLayer(cz CzImage, l *Layer) (clipped int, err error) {
+	m := GetMetadata(cz)
+	x, y := czPosition(m)
+	pic, clipped := SplitLayer(l, image.Rect(x, y, x+int(m.Width), y+int(m.Height)))
+	buf := &bytes.Buffer{}
+	if err = png.Encode(buf, pic); err != nil {
+		return 0, err
+	}
+	return clipped, cz.Import(buf, false)
+}
@@ -0,0 +1,89 @@
+package czimage
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestORARoundTrip(t *testing.T) {
+	body := testImage(40, 60, false)
+	face := testImage(10, 8, true)
+	bodyCz, err := ReadCzImage(encodeCz(t, body, EncodeOptions{Type: "cz3", X: 100, Y: 20}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	faceCz, err := ReadCzImage(encodeCz(t, face, EncodeOptions{Type: "cz3", X: 115, Y: 30}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers := make([]*Layer, 2)
+	if layers[0], err = CzLayer("body.cz3", bodyCz); err != nil {
+		t.Fatal(err)
+	}
+	if layers[1], err = CzLayer("face.cz3", faceCz); err != nil {
+		t.Fatal(err)
+	}
+	layers[1].Hidden = true
+
+	buf := &bytes.Buffer{}
+	if err = WriteORA(buf, layers); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := zr.File[0]; f.Name != "mimetype" || f.Method != zip.Store {
+		t.Errorf("first file %s, method %d", f.Name, f.Method)
+	}
+
+	got, err := ReadORA(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Name != "body.cz3" || got[1].Name != "face.cz3" || got[0].Hidden || !got[1].Hidden {
+		t.Fatalf("layers %+v %+v", got[0], got[1])
+	}
+	if got[1].X != 115 || got[1].Y != 30 || !bytes.Equal(got[1].Image.Pix, face.Pix) {
+		t.Errorf("face layer at %d,%d", got[1].X, got[1].Y)
+	}
+
+	// 编辑软件将图层裁剪为内容范围，并在cz范围外绘制
+	edited := &Layer{Name: "face.cz3", X: 113, Y: 32, Image: image.NewNRGBA(image.Rect(0, 0, 5, 3))}
+	for i := range edited.Image.Pix {
+		edited.Image.Pix[i] = 0xFF
+	}
+	clipped, err := ImportLayer(faceCz, edited)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clipped != 6 {
+		t.Errorf("%d pixels clipped, want 6", clipped)
+	}
+	out := &bytes.Buffer{}
+	if err = faceCz.Write(out); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := ReadCzImage(out.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := GetMetadata(reloaded); m.Width != 10 || m.Height != 8 || m.Position.X != 115 || m.Position.Y != 30 {
+		t.Errorf("metadata %+v %+v", m, m.Position)
+	}
+	pic := czPixels(t, reloaded)
+	white := color.NRGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}
+	if c := pic.NRGBAAt(0, 2); c != white {
+		t.Errorf("pixel 0,2 = %v", c)
+	}
+	if c := pic.NRGBAAt(3, 2); c.A != 0 {
+		t.Errorf("pixel 3,2 = %v, want transparent", c)
+	}
+
+	if _, err = ReadORA(bytes.NewReader([]byte("PK")), 2); err == nil {
+		t.Error("expected an error for a non-zip input")
+	}
+}
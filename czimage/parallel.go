@@ -0,0 +1,277 @@
+package czimage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"runtime"
+	"sync"
+)
+
+const (
+	// parallelSegmentSize 并行压缩时每个任务处理的未压缩数据大小
+	parallelSegmentSize = 1 << 18
+	// maxBlockCodes Compress 每块最多的lzw编码数，字典不超过uint16
+	maxBlockCodes = 0xFEFD
+	// maxBlockCodes2 Compress2 字典上限，编码最多18位
+	maxBlockCodes2 = 0x40000
+)
+
+// compressLZWBlock lzw压缩一块，不向下一块传递element
+//
+//	Description 每块使用独立的字典并在块尾写出剩余的element，
+//	  因此各块可以独立压缩，Decompress 按块解压的结果不变
+//	Param data []byte 未压缩数据
+//	Param size int 压缩后编码数量的限制，0为不限制
+//	Return count 使用数据量
+//	Return compressed 压缩后的数据
+func compressLZWBlock(data []byte, size int) (count int, compressed []uint16) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+	// 以 前缀编码<<8|字节 作为键，与 compressLZW 的字符串字典等价
+	dictionary := make(map[uint32]uint16)
+	dictionaryCount := uint16(257)
+	prefix := uint16(data[0])
+	count = 1
+	for _, c := range data[1:] {
+		key := uint32(prefix)<<8 | uint32(c)
+		if code, ok := dictionary[key]; ok {
+			prefix = code
+			count++
+			continue
+		}
+		// 保留一个位置给块尾的element
+		if size > 0 && len(compressed)+1 >= size {
+			break
+		}
+		compressed = append(compressed, prefix)
+		dictionary[key] = dictionaryCount
+		dictionaryCount++
+		prefix = uint16(c)
+		count++
+	}
+	compressed = append(compressed, prefix)
+	return count, compressed
+}
+
+// compressLZW2Block CZ2 lzw压缩一块，不向下一块传递element
+//
+//	Description 同 compressLZWBlock，编码写入15/18位的位流
+//	Param data []byte 未压缩数据
+//	Param size int 压缩后字节数的限制，0为不限制
+//	Return count 使用数据量
+//	Return compressed 压缩后的数据
+func compressLZW2Block(data []byte, size int) (count int, compressed []byte) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+	// 每个编码最多19位，每个字节最多产生一个编码
+	bufSize := len(data)*3 + 8
+	if size > 0 && size+8 < bufSize {
+		bufSize = size + 8
+	}
+	bitIO := NewBitIO(make([]byte, bufSize))
+	writeBit := func(code uint32) {
+		if code > 0x7FFF {
+			bitIO.WriteBit(1, 1)
+			bitIO.WriteBit(uint64(code), 18)
+		} else {
+			bitIO.WriteBit(0, 1)
+			bitIO.WriteBit(uint64(code), 15)
+		}
+	}
+	dictionary := make(map[uint32]uint32)
+	dictionaryCount := uint32(257)
+	prefix := uint32(data[0])
+	count = 1
+	for _, c := range data[1:] {
+		key := prefix<<8 | uint32(c)
+		if code, ok := dictionary[key]; ok {
+			prefix = code
+			count++
+			continue
+		}
+		// 保留两个编码（最多5字节）的位置
+		if size > 0 && bitIO.ByteSize()+5 > size {
+			break
+		}
+		writeBit(prefix)
+		if dictionaryCount < maxBlockCodes2 {
+			dictionary[key] = dictionaryCount
+			dictionaryCount++
+		}
+		prefix = uint32(c)
+		count++
+	}
+	writeBit(prefix)
+	return count, bitIO.Bytes()
+}
+
+// compressedBlock 压缩后的一块
+type compressedBlock struct {
+	data    []byte
+	codes   int // CompressedSize，Compress为编码数，Compress2为字节数
+	rawSize int
+}
+
+// compressSegments 并行压缩多段数据
+//
+//	Description 每段由一个goroutine压缩为一块或多块，结果按段的顺序拼接
+//	Param data []byte 未压缩数据
+//	Param bounds []int 各段的结束位置
+//	Param jobs int 并行数量，小于1时使用CPU数量
+//	Param fn func(segment []byte) []compressedBlock
+//	Return compressed
+//	Return outputInfo
+func compressSegments(data []byte, bounds []int, jobs int, fn func(segment []byte) []compressedBlock) (compressed []byte, outputInfo *CzOutputInfo) {
+	if jobs < 1 {
+		jobs = runtime.NumCPU()
+	}
+	if jobs > len(bounds) {
+		jobs = len(bounds)
+	}
+	results := make([][]compressedBlock, len(bounds))
+	var wg sync.WaitGroup
+	ch := make(chan int)
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range ch {
+				start := 0
+				if i > 0 {
+					start = bounds[i-1]
+				}
+				results[i] = fn(data[start:bounds[i]])
+			}
+		}()
+	}
+	for i := range bounds {
+		ch <- i
+	}
+	close(ch)
+	wg.Wait()
+
+	outputBuf := &bytes.Buffer{}
+	outputInfo = &CzOutputInfo{
+		TotalRawSize: len(data),
+		BlockInfo:    make([]CzBlockInfo, 0, len(bounds)),
+	}
+	for _, blocks := range results {
+		for _, b := range blocks {
+			outputBuf.Write(b.data)
+			outputInfo.BlockInfo = append(outputInfo.BlockInfo, CzBlockInfo{
+				CompressedSize: uint32(b.codes),
+				RawSize:        uint32(b.rawSize),
+			})
+			outputInfo.TotalCompressedSize += b.codes
+			outputInfo.FileCount++
+		}
+	}
+	outputInfo.Offset = 4 + int(outputInfo.FileCount)*8
+	return outputBuf.Bytes(), outputInfo
+}
+
+// segmentBounds 按固定大小切分数据
+func segmentBounds(length, segment int) []int {
+	bounds := make([]int, 0, length/segment+1)
+	for end := segment; end < length; end += segment {
+		bounds = append(bounds, end)
+	}
+	return append(bounds, length)
+}
+
+// rawSizeBounds 按原分块的RawSize切分数据，剩余数据作为最后一段
+func rawSizeBounds(length int, targetRawSizes []int) []int {
+	bounds := make([]int, 0, len(targetRawSizes)+1)
+	offset := 0
+	for _, rawSize := range targetRawSizes {
+		if rawSize <= 0 || offset >= length {
+			break
+		}
+		offset += rawSize
+		if offset > length {
+			offset = length
+		}
+		bounds = append(bounds, offset)
+	}
+	if offset < length {
+		bounds = append(bounds, length)
+	}
+	return bounds
+}
+
+// blocksLZW 将一段数据压缩为不超过size个编码的块
+func blocksLZW(segment []byte, size int) []compressedBlock {
+	var blocks []compressedBlock
+	for offset := 0; offset < len(segment); {
+		count, codes := compressLZWBlock(segment[offset:], size)
+		data := make([]byte, len(codes)*2)
+		for i, d := range codes {
+			binary.LittleEndian.PutUint16(data[i*2:], d)
+		}
+		blocks = append(blocks, compressedBlock{data: data, codes: len(codes), rawSize: count})
+		offset += count
+	}
+	return blocks
+}
+
+// blocksLZW2 将一段数据压缩为不超过size字节的块
+func blocksLZW2(segment []byte, size int) []compressedBlock {
+	var blocks []compressedBlock
+	for offset := 0; offset < len(segment); {
+		count, data := compressLZW2Block(segment[offset:], size)
+		blocks = append(blocks, compressedBlock{data: data, codes: len(data), rawSize: count})
+		offset += count
+	}
+	return blocks
+}
+
+// CompressParallel 并行压缩数据
+//
+//	Description 数据按固定大小分段并行压缩，每段再按size切分为块。
+//	  各块不传递element，分块方式与 Compress 不同，但 Decompress 的结果相同
+//	Param data []byte 未压缩数据
+//	Param size int 每块最多的编码数，0为默认值
+//	Param jobs int 并行数量，小于1时使用CPU数量
+//	Return compressed
+//	Return outputInfo
+func CompressParallel(data []byte, size int, jobs int) (compressed []byte, outputInfo *CzOutputInfo) {
+	if size <= 0 || size > maxBlockCodes {
+		size = maxBlockCodes
+	}
+	return compressSegments(data, segmentBounds(len(data), parallelSegmentSize), jobs, func(segment []byte) []compressedBlock {
+		return blocksLZW(segment, size)
+	})
+}
+
+// Compress2Parallel 并行压缩数据 CZ2专用
+//
+//	Description 同 CompressParallel，size为每块压缩后的字节数
+//	Param data []byte 未压缩数据
+//	Param size int 每块最多的字节数，0为默认值
+//	Param jobs int 并行数量，小于1时使用CPU数量
+//	Return compressed
+//	Return outputInfo
+func Compress2Parallel(data []byte, size int, jobs int) (compressed []byte, outputInfo *CzOutputInfo) {
+	if size <= 0 {
+		size = 0x87BDF
+	}
+	return compressSegments(data, segmentBounds(len(data), parallelSegmentSize), jobs, func(segment []byte) []compressedBlock {
+		return blocksLZW2(segment, size)
+	})
+}
+
+// Compress2WithRawSizesParallel 并行压缩数据，保留原分块的RawSize CZ2专用
+//
+//	Description 输出与 Compress2WithRawSizes 相同
+//	Param data []byte 未压缩数据
+//	Param targetRawSizes []int 原分块的RawSize
+//	Param jobs int 并行数量，小于1时使用CPU数量
+//	Return compressed
+//	Return outputInfo
+func Compress2WithRawSizesParallel(data []byte, targetRawSizes []int, jobs int) (compressed []byte, outputInfo *CzOutputInfo) {
+	return compressSegments(data, rawSizeBounds(len(data), targetRawSizes), jobs, func(segment []byte) []compressedBlock {
+		return blocksLZW2(segment, 0)
+	})
+}
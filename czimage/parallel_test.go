@@ -0,0 +1,194 @@
+package czimage
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// testCG 类似CG的数据：渐变加少量噪点，w*h*bpp字节
+func testCG(w, h, bpp int) []byte {
+	r := rand.New(rand.NewSource(1))
+	data := make([]byte, w*h*bpp)
+	i := 0
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			for c := 0; c < bpp; c++ {
+				v := (x>>3 + y>>2 + c*64) & 0xFF
+				if r.Intn(8) == 0 {
+					v ^= r.Intn(4)
+				}
+				data[i] = byte(v)
+				i++
+			}
+		}
+	}
+	return data
+}
+
+func checkOutputInfo(t *testing.T, info *CzOutputInfo, length, size int) {
+	t.Helper()
+	raw := 0
+	for i, b := range info.BlockInfo {
+		if size > 0 && int(b.CompressedSize) > size {
+			t.Errorf("block %d: compressed size %d over limit %d", i, b.CompressedSize, size)
+		}
+		raw += int(b.RawSize)
+	}
+	if raw != length || int(info.FileCount) != len(info.BlockInfo) {
+		t.Errorf("raw sizes sum to %d in %d blocks, want %d", raw, info.FileCount, length)
+	}
+}
+
+func TestCompressLZWBlock(t *testing.T) {
+	data := testCG(64, 64, 4)
+	count, codes := compressLZWBlock(data, 0)
+	_, want, _ := compressLZW(data, len(data)+1, "")
+	if count != len(data) || len(codes) != len(want) {
+		t.Fatalf("count %d codes %d, want %d codes %d", count, len(codes), len(data), len(want))
+	}
+	for i := range codes {
+		if codes[i] != want[i] {
+			t.Fatalf("code %d = %d, want %d", i, codes[i], want[i])
+		}
+	}
+}
+
+func TestCompressParallel(t *testing.T) {
+	tests := []struct {
+		data []byte
+		size int
+		jobs int
+	}{
+		{[]byte{7}, 0, 4},
+		{[]byte("abababababababab"), 3, 2},
+		{testCG(64, 64, 4), 100, 3},
+		{testCG(400, 300, 4), 0, 0},
+	}
+	for _, tt := range tests {
+		compressed, info := CompressParallel(tt.data, tt.size, tt.jobs)
+		checkOutputInfo(t, info, len(tt.data), tt.size)
+		if len(compressed) != info.TotalCompressedSize*2 {
+			t.Errorf("%d bytes written, TotalCompressedSize %d", len(compressed), info.TotalCompressedSize)
+		}
+		if got, err := Decompress(compressed, info); err != nil || !bytes.Equal(got, tt.data) {
+			t.Errorf("size %d: round trip differs (%d bytes, want %d): %v", tt.size, len(got), len(tt.data), err)
+		}
+	}
+}
+
+func TestCompress2Parallel(t *testing.T) {
+	tests := []struct {
+		data []byte
+		size int
+	}{
+		{[]byte{0xFF}, 0},
+		{testCG(64, 64, 1), 64},
+		{testCG(640, 480, 1), 0},
+	}
+	for _, tt := range tests {
+		compressed, info := Compress2Parallel(tt.data, tt.size, 4)
+		checkOutputInfo(t, info, len(tt.data), tt.size)
+		if got, err := Decompress2(compressed, info); err != nil || !bytes.Equal(got, tt.data) {
+			t.Errorf("size %d: round trip differs (%d bytes, want %d): %v", tt.size, len(got), len(tt.data), err)
+		}
+	}
+}
+
+func TestCompress2WithRawSizesParallel(t *testing.T) {
+	data := testCG(300, 200, 1)
+	sizes := []int{10000, 20000, 5}
+	want, wantInfo := Compress2WithRawSizes(data, sizes)
+	got, info := Compress2WithRawSizesParallel(data, sizes, 3)
+	if !bytes.Equal(got, want) {
+		t.Fatal("output differs from Compress2WithRawSizes")
+	}
+	if len(info.BlockInfo) != len(wantInfo.BlockInfo) {
+		t.Fatalf("%d blocks, want %d", len(info.BlockInfo), len(wantInfo.BlockInfo))
+	}
+	for i := range info.BlockInfo {
+		if info.BlockInfo[i] != wantInfo.BlockInfo[i] {
+			t.Errorf("block %d = %v, want %v", i, info.BlockInfo[i], wantInfo.BlockInfo[i])
+		}
+	}
+	if out, err := Decompress2(got, info); err != nil || !bytes.Equal(out, data) {
+		t.Errorf("round trip differs: %v", err)
+	}
+}
+
+func TestImportJobs(t *testing.T) {
+	src := testImage(300, 200, false)
+	for _, typ := range []string{"cz1", "cz2", "cz3", "cz4"} {
+		opts := EncodeOptions{Type: typ, Jobs: 4}
+		if typ == "cz2" {
+			src = testImage(300, 200, true)
+		}
+		cz, err := Encode(src, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", typ, err)
+		}
+		buf := &bytes.Buffer{}
+		if err = cz.Write(buf); err != nil {
+			t.Fatalf("%s: %v", typ, err)
+		}
+		got := czPixels(t, LoadCzImage(buf.Bytes()))
+		want := ImageToNRGBA(src)
+		if typ == "cz2" {
+			// cz2只保存alpha
+			for i := 3; i < len(want.Pix); i += 4 {
+				if got.Pix[i] != want.Pix[i] {
+					t.Fatalf("%s: alpha differs at %d", typ, i/4)
+				}
+			}
+			continue
+		}
+		if !bytes.Equal(got.Pix, want.Pix) {
+			t.Errorf("%s: pixels differ", typ)
+		}
+	}
+}
+
+func BenchmarkCompress(b *testing.B) {
+	benchCG := testCG(1920, 1080, 4)
+	b.SetBytes(int64(len(benchCG)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Compress(benchCG, 0)
+	}
+}
+
+func BenchmarkCompressParallel(b *testing.B) {
+	benchCG := testCG(1920, 1080, 4)
+	b.SetBytes(int64(len(benchCG)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CompressParallel(benchCG, 0, 0)
+	}
+}
+
+func BenchmarkCompressParallel1(b *testing.B) {
+	benchCG := testCG(1920, 1080, 4)
+	b.SetBytes(int64(len(benchCG)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CompressParallel(benchCG, 0, 1)
+	}
+}
+
+func BenchmarkCompress2(b *testing.B) {
+	data := testCG(1920, 1080, 1)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Compress2(data, 0)
+	}
+}
+
+func BenchmarkCompress2Parallel(b *testing.B) {
+	data := testCG(1920, 1080, 1)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Compress2Parallel(data, 0, 0)
+	}
+}
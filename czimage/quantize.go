@@ -0,0 +1,269 @@
+package czimage
+
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+// colorBox 中位切分中的一组颜色
+type colorBox struct {
+	colors []color.NRGBA
+	counts []int
+}
+
+// channel 第i个通道的值，0-3 为 R、G、B、A
+func channel(c color.NRGBA, i int) uint8 {
+	switch i {
+	case 0:
+		return c.R
+	case 1:
+		return c.G
+	case 2:
+		return c.B
+	}
+	return c.A
+}
+
+// widest 取值范围最大的通道与范围
+func (b *colorBox) widest() (int, int) {
+	best, bestRange := 0, -1
+	for ch := 0; ch < 4; ch++ {
+		lo, hi := 255, 0
+		for _, c := range b.colors {
+			v := int(channel(c, ch))
+			if v < lo {
+				lo = v
+			}
+			if v > hi {
+				hi = v
+			}
+		}
+		if hi-lo > bestRange {
+			best, bestRange = ch, hi-lo
+		}
+	}
+	return best, bestRange
+}
+
+// split 按像素数的中位数将颜色分为两组
+func (b *colorBox) split(ch int) (*colorBox, *colorBox) {
+	order := make([]int, len(b.colors))
+	total := 0
+	for i := range order {
+		order[i] = i
+		total += b.counts[i]
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return channel(b.colors[order[i]], ch) < channel(b.colors[order[j]], ch)
+	})
+	mid, sum := 1, 0
+	for k, i := range order[:len(order)-1] {
+		sum += b.counts[i]
+		mid = k + 1
+		if sum*2 >= total {
+			break
+		}
+	}
+	left, right := &colorBox{}, &colorBox{}
+	for k, i := range order {
+		dst := right
+		if k < mid {
+			dst = left
+		}
+		dst.colors = append(dst.colors, b.colors[i])
+		dst.counts = append(dst.counts, b.counts[i])
+	}
+	return left, right
+}
+
+// average 按像素数加权的平均颜色，RGB按alpha加权
+func (b *colorBox) average() color.NRGBA {
+	var r, g, bl, a, n, an float64
+	for i, c := range b.colors {
+		w := float64(b.counts[i])
+		aw := w * float64(c.A)
+		r += float64(c.R) * aw
+		g += float64(c.G) * aw
+		bl += float64(c.B) * aw
+		a += float64(c.A) * w
+		n += w
+		an += aw
+	}
+	if an == 0 {
+		return color.NRGBA{A: uint8(a/n + 0.5)}
+	}
+	return color.NRGBA{
+		R: uint8(r/an + 0.5),
+		G: uint8(g/an + 0.5),
+		B: uint8(bl/an + 0.5),
+		A: uint8(a/n + 0.5),
+	}
+}
+
+// Quantize 使用中位切分生成调色板
+//
+//	Description 在RGBA四个通道上切分，完全透明的像素视为同一颜色并保留在第0项。
+//	  颜色数不超过count时直接使用图像中的颜色
+//	Param pic *image.NRGBA
+//	Param count int 调色板大小，cz1为16或256
+//	Return []color.NRGBA 长度为count，不足部分为透明黑
+func Quantize(pic *image.NRGBA, count int) []color.NRGBA {
+	hist := make(map[color.NRGBA]int)
+	for y := pic.Rect.Min.Y; y < pic.Rect.Max.Y; y++ {
+		for x := pic.Rect.Min.X; x < pic.Rect.Max.X; x++ {
+			c := pic.NRGBAAt(x, y)
+			if c.A == 0 {
+				c = color.NRGBA{}
+			}
+			hist[c]++
+		}
+	}
+	palette := make([]color.NRGBA, 0, count)
+	if _, ok := hist[color.NRGBA{}]; ok {
+		palette = append(palette, color.NRGBA{})
+		delete(hist, color.NRGBA{})
+	}
+	box := &colorBox{}
+	for c := range hist {
+		box.colors = append(box.colors, c)
+	}
+	// map的遍历顺序不固定，排序后结果可复现
+	sort.Slice(box.colors, func(i, j int) bool {
+		a, b := box.colors[i], box.colors[j]
+		return uint32(a.R)<<24|uint32(a.G)<<16|uint32(a.B)<<8|uint32(a.A) <
+			uint32(b.R)<<24|uint32(b.G)<<16|uint32(b.B)<<8|uint32(b.A)
+	})
+	for _, c := range box.colors {
+		box.counts = append(box.counts, hist[c])
+	}
+
+	if len(box.colors) <= count-len(palette) {
+		palette = append(palette, box.colors...)
+	} else if len(box.colors) > 0 {
+		boxes := []*colorBox{box}
+		for len(boxes) < count-len(palette) {
+			// 优先切分范围最大的一组
+			best, bestCh, bestRange := -1, 0, 0
+			for i, b := range boxes {
+				if len(b.colors) < 2 {
+					continue
+				}
+				if ch, r := b.widest(); r > bestRange {
+					best, bestCh, bestRange = i, ch, r
+				}
+			}
+			if best < 0 {
+				break
+			}
+			left, right := boxes[best].split(bestCh)
+			boxes[best] = left
+			boxes = append(boxes, right)
+		}
+		for _, b := range boxes {
+			palette = append(palette, b.average())
+		}
+	}
+	result := make([]color.NRGBA, count)
+	copy(result, palette)
+	return result
+}
+
+// closestIndex 调色板中与c最接近的颜色
+func closestIndex(palette []color.NRGBA, c color.NRGBA) uint8 {
+	bestIdx := uint8(0)
+	bestDist := int(^uint(0) >> 1) // max int
+	for i, pc := range palette {
+		dr := int(c.R) - int(pc.R)
+		dg := int(c.G) - int(pc.G)
+		db := int(c.B) - int(pc.B)
+		da := int(c.A) - int(pc.A)
+		dist := dr*dr + dg*dg + db*db + da*da
+		if dist == 0 {
+			return uint8(i)
+		}
+		if dist < bestDist {
+			bestDist = dist
+			bestIdx = uint8(i)
+		}
+	}
+	return bestIdx
+}
+
+// MapPalette 将图像转换为调色板下标
+//
+//	Description dither为true时使用Floyd–Steinberg抖动，完全透明的像素不扩散误差
+//	Param pic *image.NRGBA
+//	Param palette []color.NRGBA
+//	Param dither bool
+//	Return []uint8 按行排列的调色板下标
+func MapPalette(pic *image.NRGBA, palette []color.NRGBA, dither bool) []uint8 {
+	width, height := pic.Rect.Dx(), pic.Rect.Dy()
+	indexes := make([]uint8, width*height)
+	if !dither {
+		cache := make(map[color.NRGBA]uint8)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				c := pic.NRGBAAt(pic.Rect.Min.X+x, pic.Rect.Min.Y+y)
+				idx, ok := cache[c]
+				if !ok {
+					idx = closestIndex(palette, c)
+					cache[c] = idx
+				}
+				indexes[y*width+x] = idx
+			}
+		}
+		return indexes
+	}
+
+	// 当前行与下一行的误差，每个像素4个通道，两端各多一个像素
+	curr := make([]float32, (width+2)*4)
+	next := make([]float32, (width+2)*4)
+	clamp := func(v float32) uint8 {
+		if v < 0 {
+			return 0
+		}
+		if v > 255 {
+			return 255
+		}
+		return uint8(v + 0.5)
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			src := pic.NRGBAAt(pic.Rect.Min.X+x, pic.Rect.Min.Y+y)
+			e := curr[(x+1)*4 : (x+2)*4]
+			c := src
+			if src.A != 0 {
+				c = color.NRGBA{
+					R: clamp(float32(src.R) + e[0]),
+					G: clamp(float32(src.G) + e[1]),
+					B: clamp(float32(src.B) + e[2]),
+					A: clamp(float32(src.A) + e[3]),
+				}
+			}
+			idx := closestIndex(palette, c)
+			indexes[y*width+x] = idx
+			if src.A == 0 {
+				continue
+			}
+			p := palette[idx]
+			diff := [4]float32{
+				float32(c.R) - float32(p.R),
+				float32(c.G) - float32(p.G),
+				float32(c.B) - float32(p.B),
+				float32(c.A) - float32(p.A),
+			}
+			for ch := 0; ch < 4; ch++ {
+				curr[(x+2)*4+ch] += diff[ch] * 7 / 16
+				next[x*4+ch] += diff[ch] * 3 / 16
+				next[(x+1)*4+ch] += diff[ch] * 5 / 16
+				next[(x+2)*4+ch] += diff[ch] * 1 / 16
+			}
+		}
+		curr, next = next, curr
+		for i := range next {
+			next[i] = 0
+		}
+	}
+	return indexes
+}
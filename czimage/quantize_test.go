@@ -0,0 +1,72 @@
+package czimage
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestQuantizeExact(t *testing.T) {
+	pic := image.NewNRGBA(image.Rect(0, 0, 4, 1))
+	pic.SetNRGBA(0, 0, color.NRGBA{R: 10, A: 0})
+	pic.SetNRGBA(1, 0, color.NRGBA{R: 255, A: 255})
+	pic.SetNRGBA(2, 0, color.NRGBA{G: 255, A: 128})
+	pic.SetNRGBA(3, 0, color.NRGBA{R: 255, A: 255})
+	palette := Quantize(pic, 16)
+	if len(palette) != 16 || palette[0] != (color.NRGBA{}) {
+		t.Fatalf("palette = %v", palette)
+	}
+	indexes := MapPalette(pic, palette, false)
+	for x, idx := range indexes {
+		want := pic.NRGBAAt(x, 0)
+		if want.A == 0 {
+			want = color.NRGBA{}
+		}
+		if palette[idx] != want {
+			t.Errorf("pixel %d = %v, want %v", x, palette[idx], want)
+		}
+	}
+}
+
+func TestQuantizeGradient(t *testing.T) {
+	pic := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			pic.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 4), G: uint8(y * 4), B: 128, A: uint8(255 - y)})
+		}
+	}
+	palette := Quantize(pic, 16)
+	for _, c := range palette {
+		if c.A == 0 {
+			t.Fatalf("unexpected transparent entry in %v", palette)
+		}
+	}
+
+	// 灰色在黑白调色板上抖动后，平均亮度应接近原值
+	gray := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+	for i := 0; i < len(gray.Pix); i += 4 {
+		copy(gray.Pix[i:], []byte{128, 128, 128, 255})
+	}
+	bw := []color.NRGBA{{A: 255}, {R: 255, G: 255, B: 255, A: 255}}
+	mean := func(indexes []uint8) float64 {
+		sum := 0
+		for _, idx := range indexes {
+			sum += int(bw[idx].R)
+		}
+		return float64(sum) / float64(len(indexes))
+	}
+	if m := mean(MapPalette(gray, bw, true)); m < 120 || m > 136 {
+		t.Errorf("dithered mean %.1f", m)
+	}
+	if m := mean(MapPalette(gray, bw, false)); m != 255 && m != 0 {
+		t.Errorf("plain mean %.1f", m)
+	}
+
+	cz, err := Encode(pic, EncodeOptions{Type: "cz1", Colorbits: 8, Dither: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := cz.(*Cz1Image); len(c.ColorPanel) != 256 {
+		t.Fatalf("palette size %d", len(c.ColorPanel))
+	}
+}
@@ -0,0 +1,315 @@
+package czimage
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// TextBox 绘制到cz图像中的一段文字
+type TextBox struct {
+	Entry        string          // cz文件名
+	Text         string          // 文字，\n换行
+	Rect         image.Rectangle // 文字区域
+	Font         string          // ttf/otf文件，为空时使用默认字体
+	Size         float64         // 字号，0则为区域高度的3/4；放不下时自动缩小
+	Color        color.NRGBA     // 文字颜色，默认白色
+	Outline      int             // 描边宽度
+	OutlineColor color.NRGBA     // 描边颜色，默认黑色
+	Align        string          // left、center、right，默认center；垂直方向居中
+	Erase        string          // 绘制前清除区域：空为直接叠加，clear为透明，或填充的颜色
+}
+
+// textBoxColumns TSV中必须存在的列
+var textBoxColumns = []string{"entry", "text", "box"}
+
+// ReadTextBoxes 读取文字排版TSV
+//
+//	Description 第一行为表头，列的顺序任意：entry text box font size color outline align erase，
+//	  box为"x,y,宽,高"，outline为"宽度[,颜色]"，颜色为#RRGGBB或#RRGGBBAA。
+//	  文字中的\n、\t为换行与制表符，空行与#开头的行被忽略
+//	Param r io.Reader
+//	Return []*TextBox
+//	Return error
+func ReadTextBoxes(r io.Reader) ([]*TextBox, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var (
+		columns map[string]int
+		boxes   []*TextBox
+		lineNo  int
+	)
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(strings.TrimSpace(line)) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if columns == nil {
+			columns = make(map[string]int)
+			for i, name := range fields {
+				columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+			}
+			for _, name := range textBoxColumns {
+				if _, ok := columns[name]; !ok {
+					return nil, fmt.Errorf("line %d: missing column %q in header", lineNo, name)
+				}
+			}
+			continue
+		}
+		get := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(fields) {
+				return strings.TrimSpace(fields[i])
+			}
+			return ""
+		}
+		box, err := parseTextBox(get)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		boxes = append(boxes, box)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if columns == nil {
+		return nil, fmt.Errorf("empty TSV")
+	}
+	return boxes, nil
+}
+
+// parseTextBox 解析TSV的一行
+func parseTextBox(get func(name string) string) (box *TextBox, err error) {
+	box = &TextBox{
+		Entry:        get("entry"),
+		Text:         unescapeTSV(get("text")),
+		Font:         get("font"),
+		Color:        color.NRGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF},
+		OutlineColor: color.NRGBA{A: 0xFF},
+		Align:        strings.ToLower(get("align")),
+		Erase:        strings.ToLower(get("erase")),
+	}
+	if len(box.Entry) == 0 {
+		return nil, fmt.Errorf("empty entry")
+	}
+	var rect [4]int
+	parts := strings.Split(get("box"), ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("box %q: want x,y,width,height", get("box"))
+	}
+	for i, p := range parts {
+		if rect[i], err = strconv.Atoi(strings.TrimSpace(p)); err != nil {
+			return nil, fmt.Errorf("box %q: %v", get("box"), err)
+		}
+	}
+	if rect[2] <= 0 || rect[3] <= 0 {
+		return nil, fmt.Errorf("box %q: empty rectangle", get("box"))
+	}
+	box.Rect = image.Rect(rect[0], rect[1], rect[0]+rect[2], rect[1]+rect[3])
+	if s := get("size"); len(s) != 0 {
+		if box.Size, err = strconv.ParseFloat(s, 64); err != nil {
+			return nil, fmt.Errorf("size %q: %v", s, err)
+		}
+	}
+	if s := get("color"); len(s) != 0 {
+		if box.Color, err = ParseColor(s); err != nil {
+			return nil, err
+		}
+	}
+	if s := get("outline"); len(s) != 0 {
+		parts := strings.SplitN(s, ",", 2)
+		if box.Outline, err = strconv.Atoi(strings.TrimSpace(parts[0])); err != nil || box.Outline < 0 {
+			return nil, fmt.Errorf("outline %q: want width[,color]", s)
+		}
+		if len(parts) == 2 {
+			if box.OutlineColor, err = ParseColor(parts[1]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	switch box.Align {
+	case "", "left", "center", "right":
+	default:
+		return nil, fmt.Errorf("align %q: want left, center or right", box.Align)
+	}
+	if box.Erase != "" && box.Erase != "clear" {
+		if _, err = ParseColor(box.Erase); err != nil {
+			return nil, fmt.Errorf("erase: %v", err)
+		}
+	}
+	return box, nil
+}
+
+// unescapeTSV 还原TSV中的\n、\t、\\
+func unescapeTSV(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\t`, "\t").Replace(s)
+}
+
+// ParseColor 解析#RRGGBB或#RRGGBBAA
+func ParseColor(s string) (color.NRGBA, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(s) != 6 && len(s) != 8 {
+		return color.NRGBA{}, fmt.Errorf("color %q: want #RRGGBB or #RRGGBBAA", s)
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("color %q: %v", s, err)
+	}
+	if len(s) == 6 {
+		v = v<<8 | 0xFF
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// DrawText 在图像中绘制文字
+//
+//	Description 按Erase清除区域，文字与描边只绘制在区域内。
+//	  文字放不下时逐步缩小字号
+//	Param dst *image.NRGBA
+//	Param box *TextBox
+//	Param f *opentype.Font
+//	Return size float64 实际使用的字号
+//	Return err error
+func DrawText(dst *image.NRGBA, box *TextBox, f *opentype.Font) (size float64, err error) {
+	rect := box.Rect.Intersect(dst.Bounds())
+	if rect.Empty() {
+		return 0, fmt.Errorf("%s: box %v is outside the %v image", box.Entry, box.Rect, dst.Bounds().Size())
+	}
+	switch box.Erase {
+	case "":
+	case "clear":
+		draw.Draw(dst, rect, image.Transparent, image.Point{}, draw.Src)
+	default:
+		c, err := ParseColor(box.Erase)
+		if err != nil {
+			return 0, err
+		}
+		draw.Draw(dst, rect, image.NewUniform(c), image.Point{}, draw.Src)
+	}
+
+	size = box.Size
+	if size <= 0 {
+		size = float64(box.Rect.Dy()) * 3 / 4
+	}
+	lines := strings.Split(box.Text, "\n")
+	var face font.Face
+	for ; size >= 1; size-- {
+		if face, err = opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone}); err != nil {
+			return 0, err
+		}
+		if w, h := textSize(face, lines); w+2*box.Outline <= box.Rect.Dx() && h+2*box.Outline <= box.Rect.Dy() {
+			break
+		}
+		face.Close()
+		face = nil
+	}
+	if face == nil {
+		return 0, fmt.Errorf("%s: text %q does not fit in box %v", box.Entry, box.Text, box.Rect)
+	}
+	defer face.Close()
+
+	// 文字的alpha遮罩
+	mask := image.NewAlpha(dst.Bounds())
+	d := &font.Drawer{Dst: mask, Src: image.Opaque, Face: face}
+	m := face.Metrics()
+	_, h := textSize(face, lines)
+	baseline := box.Rect.Min.Y + (box.Rect.Dy()-h)/2 + m.Ascent.Ceil()
+	for i, line := range lines {
+		w := d.MeasureString(line).Ceil()
+		x := box.Rect.Min.X + (box.Rect.Dx()-w)/2
+		switch box.Align {
+		case "left":
+			x = box.Rect.Min.X + box.Outline
+		case "right":
+			x = box.Rect.Max.X - box.Outline - w
+		}
+		d.Dot = fixed.P(x, baseline+i*m.Height.Ceil())
+		d.DrawString(line)
+	}
+	if box.Outline > 0 {
+		draw.DrawMask(dst, rect, image.NewUniform(box.OutlineColor), image.Point{}, dilate(mask, rect, box.Outline), rect.Min, draw.Over)
+	}
+	draw.DrawMask(dst, rect, image.NewUniform(box.Color), image.Point{}, mask, rect.Min, draw.Over)
+	return size, nil
+}
+
+// textSize 多行文字的宽高
+func textSize(face font.Face, lines []string) (w, h int) {
+	m := face.Metrics()
+	for _, line := range lines {
+		if lw := font.MeasureString(face, line).Ceil(); lw > w {
+			w = lw
+		}
+	}
+	h = (len(lines)-1)*m.Height.Ceil() + m.Ascent.Ceil() + m.Descent.Ceil()
+	return w, h
+}
+
+// dilate 遮罩在区域内按圆形扩大radius，用于描边
+func dilate(mask *image.Alpha, rect image.Rectangle, radius int) *image.Alpha {
+	out := image.NewAlpha(mask.Bounds())
+	r2 := radius * radius
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			var a uint8
+			for dy := -radius; dy <= radius && a < 0xFF; dy++ {
+				for dx := -radius; dx <= radius; dx++ {
+					if dx*dx+dy*dy > r2 {
+						continue
+					}
+					p := image.Pt(x+dx, y+dy)
+					if !p.In(rect) {
+						continue
+					}
+					if v := mask.AlphaAt(p.X, p.Y).A; v > a {
+						a = v
+					}
+				}
+			}
+			out.SetAlpha(x, y, color.Alpha{A: a})
+		}
+	}
+	return out
+}
+
+// Typeset 在cz图像中绘制文字并重新压缩
+//
+//	Description 解码cz，依次绘制各文字后通过 Import 写回cz，之后调用 Write 保存
+//	Param cz CzImage
+//	Param boxes []*TextBox 同一cz的文字
+//	Param fonts func(file string) (*opentype.Font, error) 载入TextBox.Font
+//	Return error
+func Typeset(cz CzImage, boxes []*TextBox, fonts func(file string) (*opentype.Font, error)) error {
+	img, err := cz.GetImage()
+	if err != nil {
+		return err
+	}
+	// 复制一份，不修改cz中缓存的图像
+	pic := ImageToNRGBA(img)
+	for _, box := range boxes {
+		f, err := fonts(box.Font)
+		if err != nil {
+			return err
+		}
+		if _, err = DrawText(pic, box, f); err != nil {
+			return err
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err = png.Encode(buf, pic); err != nil {
+		return err
+	}
+	return cz.Import(buf, false)
+}
@@ -0,0 +1,162 @@
+package czimage
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+)
+
+func testFont(t *testing.T) *opentype.Font {
+	t.Helper()
+	f, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestReadTextBoxes(t *testing.T) {
+	tsv := "# UI text\n" +
+		"Entry\tText\tBox\tSize\tColor\tOutline\tAlign\tErase\n" +
+		"title.cz3\tNew\\nGame\t10,20,200,80\t24\t#FF000080\t2,#FFFFFF\tleft\tclear\n" +
+		"\n" +
+		"button.cz1\tStart\t0,0,64,16\n"
+	boxes, err := ReadTextBoxes(strings.NewReader(tsv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(boxes) != 2 {
+		t.Fatalf("%d boxes, want 2", len(boxes))
+	}
+	b := boxes[0]
+	if b.Entry != "title.cz3" || b.Text != "New\nGame" || b.Rect != image.Rect(10, 20, 210, 100) ||
+		b.Size != 24 || b.Color != (color.NRGBA{R: 0xFF, A: 0x80}) || b.Outline != 2 ||
+		b.OutlineColor != (color.NRGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}) || b.Align != "left" || b.Erase != "clear" {
+		t.Errorf("box = %+v", b)
+	}
+	if b = boxes[1]; b.Color != (color.NRGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}) || b.Size != 0 {
+		t.Errorf("defaults = %+v", b)
+	}
+
+	for _, bad := range []string{
+		"entry\ttext\n",
+		"entry\ttext\tbox\nx.cz3\thi\t1,2,3\n",
+		"entry\ttext\tbox\talign\nx.cz3\thi\t0,0,9,9\tjustify\n",
+		"entry\ttext\tbox\tcolor\nx.cz3\thi\t0,0,9,9\tred\n",
+	} {
+		if _, err = ReadTextBoxes(strings.NewReader(bad)); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+// inkBounds 不透明像素的范围
+func inkBounds(pic *image.NRGBA, rect image.Rectangle) image.Rectangle {
+	var ink image.Rectangle
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			if pic.NRGBAAt(x, y).A != 0 {
+				ink = ink.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return ink
+}
+
+func TestDrawText(t *testing.T) {
+	f := testFont(t)
+	rect := image.Rect(20, 10, 180, 50)
+	for _, align := range []string{"left", "center", "right"} {
+		pic := image.NewNRGBA(image.Rect(0, 0, 200, 60))
+		box := &TextBox{Entry: "t", Text: "Start", Rect: rect, Size: 20, Align: align,
+			Color: color.NRGBA{R: 0xFF, A: 0xFF}}
+		if _, err := DrawText(pic, box, f); err != nil {
+			t.Fatal(err)
+		}
+		ink := inkBounds(pic, pic.Bounds())
+		if ink.Empty() || !ink.In(rect) {
+			t.Fatalf("%s: ink %v outside box %v", align, ink, rect)
+		}
+		left, right := ink.Min.X-rect.Min.X, rect.Max.X-ink.Max.X
+		switch {
+		case align == "left" && left > 4,
+			align == "right" && right > 4,
+			align == "center" && (left-right > 4 || right-left > 4):
+			t.Errorf("%s: ink %v in box %v", align, ink, rect)
+		}
+	}
+
+	// 放不下时缩小字号，描边与填充
+	pic := image.NewNRGBA(image.Rect(0, 0, 100, 40))
+	pic.Pix[0] = 1
+	box := &TextBox{Entry: "t", Text: "Much too long", Rect: image.Rect(0, 0, 100, 40), Size: 40, Outline: 2,
+		Color: color.NRGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}, OutlineColor: color.NRGBA{A: 0xFF}, Erase: "#00FF00"}
+	size, err := DrawText(pic, box, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size >= 40 {
+		t.Errorf("size %v not shrunk", size)
+	}
+	if c := pic.NRGBAAt(0, 0); c != (color.NRGBA{G: 0xFF, A: 0xFF}) {
+		t.Errorf("erased corner = %v", c)
+	}
+	var black bool
+	for i := 0; i < len(pic.Pix); i += 4 {
+		black = black || pic.Pix[i] == 0 && pic.Pix[i+1] == 0 && pic.Pix[i+3] == 0xFF
+	}
+	if !black {
+		t.Error("no outline pixels")
+	}
+
+	box = &TextBox{Entry: "t", Text: "x", Rect: image.Rect(200, 200, 210, 210), Size: 8}
+	if _, err = DrawText(pic, box, f); err == nil {
+		t.Error("expected an error for a box outside the image")
+	}
+}
+
+func TestTypeset(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 120, 40))
+	for i := range src.Pix {
+		src.Pix[i] = 0x40
+	}
+	cz, err := Encode(src, EncodeOptions{Type: "cz3", X: 5, Y: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err = cz.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded := LoadCzImage(buf.Bytes())
+	f := testFont(t)
+	boxes := []*TextBox{{Entry: "a.cz3", Text: "OK", Rect: image.Rect(10, 5, 60, 35), Size: 20,
+		Color: color.NRGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}, Erase: "clear"}}
+	err = Typeset(loaded, boxes, func(string) (*opentype.Font, error) { return f, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err = loaded.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	out := LoadCzImage(buf.Bytes()).(*Cz3Image)
+	if out.X != 5 || out.Y != 6 {
+		t.Errorf("position %d,%d not kept", out.X, out.Y)
+	}
+	pic := czPixels(t, out)
+	if c := pic.NRGBAAt(100, 20); c != (color.NRGBA{R: 0x40, G: 0x40, B: 0x40, A: 0x40}) {
+		t.Errorf("pixel outside the box changed: %v", c)
+	}
+	if c := pic.NRGBAAt(11, 6); c.A != 0 {
+		t.Errorf("box not cleared: %v", c)
+	}
+	if ink := inkBounds(pic, boxes[0].Rect); ink.Empty() {
+		t.Error("no text drawn")
+	}
+}
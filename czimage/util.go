@@ -3,14 +3,24 @@ package czimage
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"github.com/golang/glog"
 	"image"
 	"image/draw"
 	"io"
+	"sync"
 
 	"github.com/go-restruct/restruct"
 )
 
+// exprOnce CzOutputInfo 的 size=FileCount 需要开启restruct的表达式支持
+var exprOnce sync.Once
+
+// enableExpr 在读写 CzOutputInfo 前开启表达式支持
+func enableExpr() {
+	exprOnce.Do(restruct.EnableExprBeta)
+}
+
 func FillImage(src image.Image, width, height int) (dst *image.NRGBA) {
 	dst = image.NewNRGBA(image.Rect(0, 0, width, height))
 	draw.Draw(dst, dst.Bounds().Add(image.Pt(0, 0)), src, src.Bounds().Min, draw.Src)
@@ -19,21 +29,32 @@ func FillImage(src image.Image, width, height int) (dst *image.NRGBA) {
 
 // GetOutputInfo 读取分块信息
 //
-//	Description 读取分块信息
+//	Description 读取分块信息，检查分块数量是否超出数据范围
 //	Param data []byte
 //	Return outputInfo
-func GetOutputInfo(data []byte) (outputInfo *CzOutputInfo) {
+//	Return err
+func GetOutputInfo(data []byte) (outputInfo *CzOutputInfo, err error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("block info truncated: %d bytes", len(data))
+	}
+	count := binary.LittleEndian.Uint32(data)
+	if uint64(count)*8 > uint64(len(data)-4) {
+		return nil, fmt.Errorf("block count %d exceeds data size %d", count, len(data))
+	}
 	outputInfo = &CzOutputInfo{}
-	err := restruct.Unpack(data, binary.LittleEndian, outputInfo)
-	if err != nil {
-		panic(err)
+	enableExpr()
+	if err = restruct.Unpack(data, binary.LittleEndian, outputInfo); err != nil {
+		return nil, err
 	}
+	outputInfo.Offset = 4 + int(outputInfo.FileCount)*8
 	for _, block := range outputInfo.BlockInfo {
 		outputInfo.TotalRawSize += int(block.RawSize)
 		outputInfo.TotalCompressedSize += int(block.CompressedSize)
 	}
-	outputInfo.Offset = 4 + int(outputInfo.FileCount)*8
-	return outputInfo
+	if outputInfo.TotalRawSize > maxRawSize {
+		return nil, fmt.Errorf("raw size %d exceeds %d", outputInfo.TotalRawSize, maxRawSize)
+	}
+	return outputInfo, nil
 }
 
 // WriteStruct 写入结构体
@@ -43,6 +64,7 @@ func GetOutputInfo(data []byte) (outputInfo *CzOutputInfo) {
 //	Param list ...interface{}
 //	Return error
 func WriteStruct(writer io.Writer, list ...interface{}) error {
+	enableExpr()
 	for _, v := range list {
 		temp, err := restruct.Pack(binary.LittleEndian, v)
 		if err != nil {
@@ -59,25 +81,29 @@ func WriteStruct(writer io.Writer, list ...interface{}) error {
 //	Param data []byte 压缩的数据
 //	Param outputInfo *CzOutputInfo 分块信息
 //	Return []byte
-func Decompress(data []byte, outputInfo *CzOutputInfo) []byte {
+//	Return error 数据不足或编码无效
+func Decompress(data []byte, outputInfo *CzOutputInfo) ([]byte, error) {
 	offset := 0
 
 	// fmt.Println("uncompress info", outputInfo)
 	outputBuf := &bytes.Buffer{}
-	for _, block := range outputInfo.BlockInfo {
+	for i, block := range outputInfo.BlockInfo {
+		size := int(block.CompressedSize) * 2
+		if size > len(data)-offset {
+			return nil, fmt.Errorf("block %d: %d compressed bytes, only %d left", i, size, len(data)-offset)
+		}
 		lzwBuf := make([]uint16, int(block.CompressedSize))
-		//offsetTemp := offset
-		for j := 0; j < int(block.CompressedSize); j++ {
+		for j := range lzwBuf {
 			lzwBuf[j] = binary.LittleEndian.Uint16(data[offset : offset+2])
 			offset += 2
 		}
-		//os.WriteFile("../data/LB_EN/IMAGE/2.ori.lzw", data[offsetTemp:offset], 0666)
-		rawBuf := decompressLZW(lzwBuf, int(block.RawSize))
-		//panic("11")
+		rawBuf, err := decompressLZW(lzwBuf, int(block.RawSize))
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %v", i, err)
+		}
 		outputBuf.Write(rawBuf)
 	}
-	//os.WriteFile("../data/LB_EN/IMAGE/32.ori", outputBuf.Bytes(), 0666)
-	return outputBuf.Bytes()
+	return outputBuf.Bytes(), nil
 
 }
 
@@ -87,21 +113,24 @@ func Decompress(data []byte, outputInfo *CzOutputInfo) []byte {
 //	Param data []byte 压缩的数据
 //	Param outputInfo *CzOutputInfo 分块信息
 //	Return []byte
-func Decompress2(data []byte, outputInfo *CzOutputInfo) []byte {
+//	Return error 数据不足或编码无效
+func Decompress2(data []byte, outputInfo *CzOutputInfo) ([]byte, error) {
 	offset := 0
 
 	outputBuf := &bytes.Buffer{}
-	for _, block := range outputInfo.BlockInfo {
-		offsetTemp := offset
-		offset += int(block.CompressedSize)
-		//_ = os.WriteFile(fmt.Sprintf("C:\\Users\\wetor\\Desktop\\Prototype\\CZ2\\32\\%d_asm.src.lzw", i),
-		//	data[offsetTemp:offset], 0666)
-		rawBuf := decompressLZW2(data[offsetTemp:offset], int(block.RawSize))
-		//_ = os.WriteFile(fmt.Sprintf("C:\\Users\\wetor\\Desktop\\Prototype\\CZ2\\32\\%d_asm.src.out", i),
-		//	rawBuf, 0666)
+	for i, block := range outputInfo.BlockInfo {
+		size := int(block.CompressedSize)
+		if size > len(data)-offset {
+			return nil, fmt.Errorf("block %d: %d compressed bytes, only %d left", i, size, len(data)-offset)
+		}
+		rawBuf, err := decompressLZW2(data[offset:offset+size], int(block.RawSize))
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %v", i, err)
+		}
+		offset += size
 		outputBuf.Write(rawBuf)
 	}
-	return outputBuf.Bytes()
+	return outputBuf.Bytes(), nil
 
 }
 
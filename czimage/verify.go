@@ -0,0 +1,125 @@
+package czimage
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// VerifyResult cz往返检查结果
+type VerifyResult struct {
+	Name   string   `json:"name"`
+	Type   string   `json:"type,omitempty"`
+	Width  int      `json:"width,omitempty"`
+	Height int      `json:"height,omitempty"`
+	Pixels int      `json:"diff_pixels"`      // 往返后不同的像素数
+	Header []string `json:"header,omitempty"` // 往返后不同的头字段
+	Error  string   `json:"error,omitempty"`  // 解码或编码失败
+}
+
+// OK 往返是否无损
+func (r *VerifyResult) OK() bool {
+	return len(r.Error) == 0 && r.Pixels == 0 && len(r.Header) == 0
+}
+
+func (r *VerifyResult) String() string {
+	switch {
+	case len(r.Error) != 0:
+		return fmt.Sprintf("%s: error: %s", r.Name, r.Error)
+	case r.OK():
+		return fmt.Sprintf("%s: OK (%s %dx%d)", r.Name, r.Type, r.Width, r.Height)
+	}
+	return fmt.Sprintf("%s: lossy (%s %dx%d): %d pixel(s) differ, header %v",
+		r.Name, r.Type, r.Width, r.Height, r.Pixels, r.Header)
+}
+
+// VerifyRoundTrip 检查cz的 Export/Import/Write 往返是否无损
+//
+//	Description 解码原cz并导出png，使用原cz作为source导入该png并写出，
+//	  再次解码后比较像素与文件头。解码或编码中的panic记录为错误
+//	Param name string 用于报告的名称
+//	Param data []byte cz文件数据
+//	Return *VerifyResult
+func VerifyRoundTrip(name string, data []byte) (result *VerifyResult) {
+	result = &VerifyResult{Name: name}
+	defer func() {
+		if e := recover(); e != nil {
+			result.Error = fmt.Sprint(e)
+		}
+	}()
+	if err := verifyRoundTrip(result, data); err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func verifyRoundTrip(result *VerifyResult, data []byte) error {
+	orig, err := ReadCzImage(data)
+	if err != nil {
+		return err
+	}
+	before := GetMetadata(orig)
+	result.Type = before.Type
+	img, err := orig.GetImage()
+	if err != nil {
+		return fmt.Errorf("decode: %v", err)
+	}
+	pic := ImageToNRGBA(img)
+	result.Width, result.Height = pic.Rect.Dx(), pic.Rect.Dy()
+
+	buf := &bytes.Buffer{}
+	if err := orig.Export(buf); err != nil {
+		return fmt.Errorf("export: %v", err)
+	}
+	// Import会修改cz，使用新载入的对象
+	cz := LoadCzImage(data)
+	if err := cz.Import(buf, false); err != nil {
+		return fmt.Errorf("import: %v", err)
+	}
+	out := &bytes.Buffer{}
+	if err := cz.Write(out); err != nil {
+		return fmt.Errorf("write: %v", err)
+	}
+
+	reloaded, err := ReadCzImage(out.Bytes())
+	if err != nil {
+		return fmt.Errorf("rewritten cz cannot be loaded: %v", err)
+	}
+	result.Header = diffMetadata(before, GetMetadata(reloaded))
+	if img, err = reloaded.GetImage(); err != nil {
+		return fmt.Errorf("rewritten cz cannot be decoded: %v", err)
+	}
+	got := ImageToNRGBA(img)
+	if got.Rect.Size() != pic.Rect.Size() {
+		result.Pixels = result.Width * result.Height
+		return nil
+	}
+	for i := 0; i+4 <= len(pic.Pix); i += 4 {
+		if !bytes.Equal(pic.Pix[i:i+4], got.Pix[i:i+4]) {
+			result.Pixels++
+		}
+	}
+	return nil
+}
+
+// diffMetadata 不同的头字段
+func diffMetadata(a, b *Metadata) []string {
+	var diff []string
+	add := func(field string, x, y interface{}) {
+		if x != y {
+			diff = append(diff, fmt.Sprintf("%s %v -> %v", field, x, y))
+		}
+	}
+	add("type", a.Type, b.Type)
+	add("width", a.Width, b.Width)
+	add("height", a.Height, b.Height)
+	add("colorbits", a.Colorbits, b.Colorbits)
+	add("colorblock", a.Colorblock, b.Colorblock)
+	add("header_length", a.HeaderLength, b.HeaderLength)
+	add("extra", a.Extra, b.Extra)
+	if a.Position != nil && b.Position != nil {
+		add("position", *a.Position, *b.Position)
+	} else {
+		add("position", a.Position == nil, b.Position == nil)
+	}
+	return diff
+}
@@ -0,0 +1,47 @@
+package czimage
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestVerifyRoundTrip(t *testing.T) {
+	src := testImage(10, 7, false)
+	for _, typ := range []string{"cz0", "cz1", "cz3", "cz4"} {
+		cz, err := Encode(src, EncodeOptions{Type: typ, X: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := &bytes.Buffer{}
+		if err = cz.Write(buf); err != nil {
+			t.Fatal(err)
+		}
+		if r := VerifyRoundTrip(typ, buf.Bytes()); !r.OK() {
+			t.Errorf("%v", r)
+		}
+	}
+}
+
+func TestVerifyRoundTripLossy(t *testing.T) {
+	cz, err := Encode(testImage(10, 7, true), EncodeOptions{Type: "cz2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err = cz.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	// cz2导入时以alpha作为调色板下标，调色板alpha不等于下标时往返有损
+	paletteStart := 15 + 3
+	for i := 0; i < 256; i++ {
+		copy(data[paletteStart+i*4:], []byte{0, 0xFF, 0, uint8(255 - i)})
+	}
+	if r := VerifyRoundTrip("font", data); r.OK() || r.Pixels == 0 {
+		t.Errorf("expected lossy result, got %v", r)
+	}
+
+	if r := VerifyRoundTrip("bad", []byte("CZ3\x00truncated header")); len(r.Error) == 0 {
+		t.Errorf("expected error, got %v", r)
+	}
+}
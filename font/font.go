@@ -70,9 +70,16 @@ func LoadLucaFont(infoFile, imageFile []byte) *LucaFont {
 	font := &LucaFont{}
 	font.Info = LoadFontInfo(infoFile)
 	font.Size = int(font.Info.FontSize)
-	font.CzImage = czimage.LoadCzImage(imageFile)
-
-	font.Image = font.CzImage.GetImage().(*image.NRGBA)
+	cz, err := czimage.ReadCzImage(imageFile)
+	if err != nil {
+		glog.Fatalln(err)
+	}
+	font.CzImage = cz
+	img, err := cz.GetImage()
+	if err != nil {
+		glog.Fatalln(err)
+	}
+	font.Image = img.(*image.NRGBA)
 	return font
 }
 
@@ -0,0 +1,161 @@
+package font
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// gridLabelHeight 每格下方标签的高度，两行
+const gridLabelHeight = 28
+
+// gridMinCell 每格的最小宽度，容纳序号与unicode
+const gridMinCell = 56
+
+var (
+	gridLine     = color.NRGBA{R: 0xB0, G: 0xB0, B: 0xB0, A: 0xFF}
+	gridAdvance  = color.NRGBA{B: 0xFF, A: 0xFF}                   // 字宽X+W
+	gridOverflow = color.NRGBA{R: 0xFF, A: 0xFF}                   // 字形超出字宽
+	gridOffset   = color.NRGBA{G: 0xA0, A: 0xFF}                   // 偏移X、Y
+	gridUnmapped = color.NRGBA{R: 0xFF, G: 0xD0, B: 0xA0, A: 0xFF} // 有字形但没有对应字符
+	gridEmpty    = color.NRGBA{R: 0xFF, G: 0xFF, B: 0xA0, A: 0xFF} // 有对应字符但没有字形
+)
+
+// GridOptions 字库网格图选项
+type GridOptions struct {
+	Scale int       // 放大倍数，0则自动使每格不小于gridMinCell
+	Face  font.Face // 绘制对应字符的字体，nil时只显示unicode编码
+}
+
+// DrawGlyphGrid 绘制字库图像的调试网格
+//
+//	Description 按info的BlockSize切分字库图像（每行100字），每格下方标出序号、
+//	  对应字符与unicode编码。绿色竖线、横线为偏移X、Y，蓝线为字宽X+W，
+//	  字形超出字宽时为红线，偏移超出格子时记入问题。
+//	  有字形但没有对应字符的格为橙色，有对应字符（空格除外）但没有字形的格为黄色
+//	Param atlas image.Image 字库cz的图像
+//	Param info *Info
+//	Param opts GridOptions
+//	Return *image.NRGBA
+//	Return []string 没有对应字符、没有字形或偏移超出格子的格
+func DrawGlyphGrid(atlas image.Image, info *Info, opts GridOptions) (*image.NRGBA, []string) {
+	block := int(info.BlockSize)
+	if block <= 0 {
+		block = int(info.FontSize)
+	}
+	scale := opts.Scale
+	if scale <= 0 {
+		scale = (gridMinCell + block - 1) / block
+	}
+	glyphSize := block * scale
+	cellW, cellH := glyphSize, glyphSize+gridLabelHeight
+	if cellW < gridMinCell {
+		cellW = gridMinCell
+	}
+
+	// 字库图像不足时，超出的格没有字形
+	bounds := atlas.Bounds()
+	count := int(info.CharNum)
+	rows := (count + 99) / 100
+	pic := image.NewNRGBA(image.Rect(0, 0, 100*cellW+1, rows*cellH+1))
+	draw.Draw(pic, pic.Rect, image.White, image.Point{}, draw.Src)
+
+	var issues []string
+	label := &font.Drawer{Dst: pic, Src: image.Black, Face: basicfont.Face7x13}
+	for index := 0; index < count; index++ {
+		cell := image.Rect(0, 0, cellW, cellH).Add(image.Pt(index%100*cellW, index/100*cellH))
+		src := image.Rect(0, 0, block, block).Add(bounds.Min).Add(image.Pt(index%100*block, index/100*block))
+		var char rune
+		if index < len(info.IndexUnicode) {
+			char = info.IndexUnicode[index]
+		}
+		var ds DrawSize
+		if index < len(info.DrawSize) {
+			ds = info.DrawSize[index]
+		}
+		offsetX, offsetY := int(ds.X), int(ds.Y)
+		advance := offsetX + int(ds.W)
+
+		// 字形的最右侧
+		inkRight := -1
+		for y := src.Min.Y; y < src.Max.Y; y++ {
+			for x := src.Min.X; x < src.Max.X; x++ {
+				if _, _, _, a := atlas.At(x, y).RGBA(); a != 0 && x-src.Min.X > inkRight {
+					inkRight = x - src.Min.X
+				}
+			}
+		}
+		switch {
+		case char == 0 && inkRight >= 0:
+			draw.Draw(pic, cell, image.NewUniform(gridUnmapped), image.Point{}, draw.Src)
+			issues = append(issues, fmt.Sprintf("index %d: glyph without a mapped character", index))
+		case char != 0 && char != ' ' && inkRight < 0:
+			draw.Draw(pic, cell, image.NewUniform(gridEmpty), image.Point{}, draw.Src)
+			issues = append(issues, fmt.Sprintf("index %d: %U %q has no glyph", index, char, char))
+		}
+		if advance > block || offsetY >= block {
+			issues = append(issues, fmt.Sprintf("index %d: offset (%d,%d) width %d outside the %d cell",
+				index, offsetX, offsetY, ds.W, block))
+		}
+
+		// 字形，放大并以深色显示alpha
+		for y := 0; y < glyphSize; y++ {
+			for x := 0; x < glyphSize; x++ {
+				_, _, _, a := atlas.At(src.Min.X+x/scale, src.Min.Y+y/scale).RGBA()
+				if a != 0 {
+					v := uint8(0xFF - a>>8)
+					pic.SetNRGBA(cell.Min.X+x, cell.Min.Y+y, color.NRGBA{R: v, G: v, B: v, A: 0xFF})
+				}
+			}
+		}
+		if offsetY > 0 && offsetY < block {
+			for x := 0; x < glyphSize; x++ {
+				pic.SetNRGBA(cell.Min.X+x, cell.Min.Y+offsetY*scale, gridOffset)
+			}
+		}
+		if offsetX > 0 && offsetX < block {
+			for y := 0; y < glyphSize; y++ {
+				pic.SetNRGBA(cell.Min.X+offsetX*scale, cell.Min.Y+y, gridOffset)
+			}
+		}
+		if advance > 0 && advance <= block {
+			c := gridAdvance
+			if inkRight >= advance {
+				c = gridOverflow
+			}
+			for y := 0; y < glyphSize; y++ {
+				pic.SetNRGBA(cell.Min.X+advance*scale, cell.Min.Y+y, c)
+			}
+		}
+
+		label.Dot = fixed.P(cell.Min.X+2, cell.Min.Y+glyphSize+12)
+		label.DrawString(fmt.Sprint(index))
+		if char != 0 {
+			label.Dot = fixed.P(cell.Min.X+cellW-30, cell.Min.Y+glyphSize+26)
+			label.DrawString(fmt.Sprintf("%04X", char))
+			if opts.Face != nil && char != ' ' {
+				d := &font.Drawer{Dst: pic, Src: image.NewUniform(color.NRGBA{R: 0xC0, A: 0xFF}), Face: opts.Face,
+					Dot: fixed.P(cell.Min.X+2, cell.Min.Y+glyphSize+26)}
+				d.DrawString(string(char))
+			}
+		}
+	}
+
+	// 网格线
+	for x := 0; x <= 100*cellW; x += cellW {
+		for y := 0; y < pic.Rect.Dy(); y++ {
+			pic.SetNRGBA(x, y, gridLine)
+		}
+	}
+	for y := 0; y <= rows*cellH; y += cellH {
+		for x := 0; x < pic.Rect.Dx(); x++ {
+			pic.SetNRGBA(x, y, gridLine)
+		}
+	}
+	return pic, issues
+}
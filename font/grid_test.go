@@ -0,0 +1,80 @@
+package font
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestDrawGlyphGrid(t *testing.T) {
+	f := CreateLucaFont(20, bytes.NewReader(goregular.TTF), " AWB")
+	info := f.Info
+	block := int(info.BlockSize)
+	// B的字形被清除，A没有对应字符，W的字宽过小
+	draw.Draw(f.Image, image.Rect(3*block, 0, 4*block, block), image.Transparent, image.Point{}, draw.Src)
+	info.IndexUnicode[1] = 0
+	for i := range info.DrawSize {
+		info.DrawSize[i].X, info.DrawSize[i].Y = 0, 0
+	}
+	info.DrawSize[2].W = 2
+
+	grid, issues := DrawGlyphGrid(f.Image, info, GridOptions{Scale: 3})
+	if len(issues) != 2 || !strings.Contains(issues[0], "index 1") || !strings.Contains(issues[1], "'B'") {
+		t.Fatalf("issues %q", issues)
+	}
+	cellW, cellH := block*3, block*3+gridLabelHeight
+	if grid.Rect != image.Rect(0, 0, 100*cellW+1, cellH+1) {
+		t.Errorf("grid %v", grid.Rect)
+	}
+	corner := func(index int) color.NRGBA {
+		return grid.NRGBAAt(index*cellW+1, cellH-1)
+	}
+	if c := corner(1); c != gridUnmapped {
+		t.Errorf("unmapped cell = %v", c)
+	}
+	if c := corner(3); c != gridEmpty {
+		t.Errorf("empty cell = %v", c)
+	}
+	if c := corner(0); c != (color.NRGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}) {
+		t.Errorf("space cell = %v", c)
+	}
+	if c := grid.NRGBAAt(2*cellW+2*3, 1); c != gridOverflow {
+		t.Errorf("advance line of W = %v, want overflow", c)
+	}
+}
+
+func TestDrawGlyphGridOffsets(t *testing.T) {
+	f := CreateLucaFont(20, bytes.NewReader(goregular.TTF), " AWB")
+	info := f.Info
+	block := int(info.BlockSize)
+	for i := range info.DrawSize {
+		info.DrawSize[i] = DrawSize{W: 10}
+	}
+	// A有偏移，W的X+W超出格子，B的Y超出格子
+	info.DrawSize[1] = DrawSize{X: 2, W: 16, Y: 3}
+	info.DrawSize[2] = DrawSize{X: 10, W: uint8(block)}
+	info.DrawSize[3] = DrawSize{W: 10, Y: uint8(block)}
+
+	grid, issues := DrawGlyphGrid(f.Image, info, GridOptions{Scale: 3})
+	if len(issues) != 2 || !strings.Contains(issues[0], "index 2") || !strings.Contains(issues[1], "index 3") {
+		t.Fatalf("issues %q", issues)
+	}
+	cellW := block * 3
+	if c := grid.NRGBAAt(cellW+2*3, 1); c != gridOffset {
+		t.Errorf("x offset line of A = %v", c)
+	}
+	if c := grid.NRGBAAt(cellW+block*3-1, 3*3); c != gridOffset {
+		t.Errorf("y offset line of A = %v", c)
+	}
+	if c := grid.NRGBAAt(cellW+(2+16)*3, 1); c != gridAdvance {
+		t.Errorf("advance line of A = %v", c)
+	}
+	if c := grid.NRGBAAt(cellW+10*3, 1); c == gridAdvance || c == gridOverflow {
+		t.Errorf("advance line of A drawn at W = %v", c)
+	}
+}
@@ -70,7 +70,11 @@ func (g *Game) LoadResources() {
 }
 
 func (g *Game) LoadScriptResources(file string) {
-	g.Resources[ResScript] = pak.LoadPak(file, g.Coding)
+	p, err := pak.Open(file, g.Coding)
+	if err != nil {
+		glog.Fatalln(err)
+	}
+	g.Resources[ResScript] = p
 	g.load()
 }
 
@@ -1,16 +1,21 @@
 module lucksystem
 
-go 1.16
+go 1.18
 
 require (
-	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/go-python/gpython v0.2.0 // indirect
 	github.com/go-restruct/restruct v1.2.0-alpha
 	github.com/golang/glog v1.0.0
-	github.com/pkg/errors v0.9.1 // indirect
 	github.com/spf13/cobra v1.5.0
-	github.com/stretchr/testify v1.7.1 // indirect
 	golang.org/x/image v0.1.0
 	golang.org/x/text v0.4.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/go-python/gpython v0.2.0 // indirect
+	github.com/inconshreveable/mousetrap v1.0.0 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	github.com/spf13/pflag v1.0.5 // indirect
+	github.com/stretchr/testify v1.7.1 // indirect
 	gopkg.in/yaml.v3 v3.0.0-20220512140231-539c8e751b99 // indirect
 )
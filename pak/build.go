@@ -0,0 +1,340 @@
+package pak
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/go-restruct/restruct"
+	"github.com/golang/glog"
+	"lucksystem/charset"
+)
+
+const (
+	// FlagNamed Flags中表示存在文件名表的位
+	FlagNamed uint32 = 512
+
+	// DefaultBlockSize 新建pak时默认的块大小
+	DefaultBlockSize uint32 = 1024
+
+	headerSize = 36 // Header 结构体大小，4 * 9
+)
+
+// NewOptions 新建pak的参数
+type NewOptions struct {
+	Coding    charset.Charset // 文件名编码
+	BlockSize uint32          // 块大小，0则使用DefaultBlockSize
+	IDStart   uint32          // 第一个文件的ID
+	Flags     uint32          // 额外的标志位，Named为true时自动加上FlagNamed
+	Named     bool            // 是否写入文件名表
+}
+
+// New 创建一个不依赖原pak文件的空pak
+//
+//	Description 通过 Add 添加文件后，使用 Write 或 Build 输出
+//	Param opts *NewOptions
+//	Return *Pak
+func New(opts *NewOptions) *Pak {
+	if opts == nil {
+		opts = &NewOptions{Named: true}
+	}
+	p := &Pak{
+		NameMap: make(map[string]int),
+		Coding:  opts.Coding,
+	}
+	if len(p.Coding) == 0 {
+		p.Coding = charset.UTF_8
+	}
+	p.BlockSize = opts.BlockSize
+	if p.BlockSize == 0 {
+		p.BlockSize = DefaultBlockSize
+	}
+	p.IDStart = opts.IDStart
+	p.Flags = opts.Flags
+	if opts.Named {
+		p.Flags |= FlagNamed
+	} else {
+		p.Flags &^= FlagNamed
+	}
+	return p
+}
+
+// NewFromDir 使用文件夹中的文件创建pak
+//
+//	Description 文件按文件名排序，若文件名均为数字则按数值排序
+//	Param dir string
+//	Param opts *NewOptions
+//	Return *Pak
+//	Return error
+func NewFromDir(dir string, opts *NewOptions) (*Pak, error) {
+	list, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(list))
+	for _, fi := range list {
+		if !fi.IsDir() {
+			names = append(names, fi.Name())
+		}
+	}
+	sortNames(names)
+
+	p := New(opts)
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		p.appendEntry(name, data)
+	}
+	return p, nil
+}
+
+// sortNames 文件名排序，全部为数字时按数值排序
+func sortNames(names []string) {
+	numeric := true
+	for _, name := range names {
+		if _, err := strconv.Atoi(name); err != nil {
+			numeric = false
+			break
+		}
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		if numeric {
+			a, _ := strconv.Atoi(names[i])
+			b, _ := strconv.Atoi(names[j])
+			return a < b
+		}
+		return names[i] < names[j]
+	})
+}
+
+// appendEntry 在末尾添加一个外部数据文件
+func (p *Pak) appendEntry(name string, data []byte) *Entry {
+	index := len(p.Files)
+	if p.Flags&FlagNamed == 0 {
+		name = strconv.Itoa(index)
+	}
+	e := &Entry{
+		Length:  uint32(len(data)),
+		Data:    data,
+		Name:    name,
+		ID:      int(p.IDStart) + index,
+		Replace: true,
+	}
+	p.Files = append(p.Files, e)
+	p.FileCount = uint32(len(p.Files))
+	if p.NameMap == nil {
+		p.NameMap = make(map[string]int)
+	}
+	p.NameMap[name] = e.ID
+	return e
+}
+
+// align 向上对齐到块大小
+func align(n, blockSize uint32) uint32 {
+	if n%blockSize != 0 {
+		n = (n/blockSize + 1) * blockSize
+	}
+	return n
+}
+
+// matchesOffsetMark 偏移表之前是否有值等于mark的位置
+//
+//	Description open() 从Flags开始寻找值为 HeaderLength/BlockSize 的位置作为偏移表开始，
+//	  Flags、HeaderExtra与文件名表偏移都不能等于该值
+func (p *Pak) matchesOffsetMark(mark, nameOffset uint32) bool {
+	if mark == p.Flags || mark == nameOffset {
+		return true
+	}
+	for i := 0; i+4 <= len(p.HeaderExtra); i += 4 {
+		if mark == binary.LittleEndian.Uint32(p.HeaderExtra[i:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Build 完整写入一个新的pak
+//
+//	Description 重新生成Header、偏移长度表与文件名表，所有文件按BlockSize对齐。
+//	  Header之后的未知数据（HeaderExtra）原样保留。
+//	  未被替换的文件数据从原pak文件中读取。Dedup为true时内容相同的文件共用同一位置，
+//	  结果记录在 DedupStats 中
+//	Receiver p *Pak
+//	Param w io.Writer
+//	Return error
+func (p *Pak) Build(w io.Writer) error {
+	if len(p.Files) == 0 {
+		return errors.New("pak has no entries")
+	}
+	if p.BlockSize == 0 {
+		p.BlockSize = DefaultBlockSize
+	}
+	named := p.Flags&FlagNamed != 0
+
+	// 1. 编码文件名表
+	nameTable := &bytes.Buffer{}
+	if named {
+		for _, e := range p.Files {
+			name, err := charset.UTF8To(p.Coding, []byte(e.Name))
+			if err != nil {
+				return err
+			}
+			nameTable.WriteString(name)
+			nameTable.WriteByte(0)
+		}
+	}
+
+	// 2. 计算Header大小
+	// Header(36) + HeaderExtra + 文件名表偏移(4) + 偏移长度表 + 文件名表
+	if len(p.HeaderExtra)%4 != 0 {
+		return fmt.Errorf("header extra data of %d bytes is not 4-byte aligned", len(p.HeaderExtra))
+	}
+	offsetPos := uint32(headerSize + len(p.HeaderExtra) + 4)
+	nameOffset := uint32(0)
+	if named {
+		nameOffset = offsetPos + 8*uint32(len(p.Files))
+	}
+	headerLength := align(offsetPos+8*uint32(len(p.Files))+uint32(nameTable.Len()), p.BlockSize)
+	// open() 从Flags开始，寻找值为 HeaderLength/BlockSize 的位置作为偏移表开始，避免误判
+	for p.matchesOffsetMark(headerLength/p.BlockSize, nameOffset) {
+		headerLength += p.BlockSize
+	}
+
+	var src io.ReaderAt
+	if len(p.FileName) > 0 {
+		f, err := os.Open(p.FileName)
+		if err != nil {
+			glog.V(8).Infoln("os.Open", err)
+			return err
+		}
+		defer f.Close()
+		src = f
+	}
+
+	// 3. 计算每个文件的新位置，保存原位置用于读取数据
+	//    Dedup时内容相同的文件共用第一个文件的位置
+	oldOffset := make([]uint32, len(p.Files))
+	for i, e := range p.Files {
+		oldOffset[i] = e.Offset
+	}
+	shared := make([]int, len(p.Files))
+	for i := range shared {
+		shared[i] = -1
+	}
+	p.DedupStats = DedupStats{}
+	if p.Dedup {
+		var err error
+		if shared, err = p.duplicates(src, oldOffset); err != nil {
+			return err
+		}
+	}
+	offset := headerLength
+	for i, e := range p.Files {
+		if j := shared[i]; j >= 0 {
+			e.Offset = p.Files[j].Offset
+			p.DedupStats.Entries++
+			p.DedupStats.Saved += int64(align(e.Length, p.BlockSize))
+			continue
+		}
+		e.Offset = offset
+		offset = align(offset+e.Length, p.BlockSize)
+	}
+
+	// 4. 写入Header
+	p.HeaderLength = headerLength
+	p.FileCount = uint32(len(p.Files))
+	header, err := restruct.Pack(binary.LittleEndian, &p.Header)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, headerLength)
+	copy(buf, header)
+	copy(buf[headerSize:], p.HeaderExtra)
+	binary.LittleEndian.PutUint32(buf[offsetPos-4:], nameOffset)
+	for i, e := range p.Files {
+		binary.LittleEndian.PutUint32(buf[int(offsetPos)+i*8:], e.Offset/p.BlockSize)
+		binary.LittleEndian.PutUint32(buf[int(offsetPos)+i*8+4:], e.Length)
+	}
+	if named {
+		copy(buf[nameOffset:], nameTable.Bytes())
+	}
+	p.OffsetPos = int64(offsetPos)
+	p.DataPos = int64(headerLength)
+
+	// 5. 写入文件数据，每个文件后补齐到BlockSize
+	// 未替换的文件从原pak流式复制；支持WriteAt时并行写入
+	if wa, ok := w.(io.WriterAt); ok && p.jobs() > 1 {
+		if _, err = wa.WriteAt(buf, 0); err != nil {
+			return err
+		}
+		err = p.parallel(p.allIndexes(), func(i int) error {
+			e := p.Files[i]
+			if shared[i] >= 0 {
+				return nil
+			}
+			if e.Replace && e.Data != nil {
+				if _, err := wa.WriteAt(e.Data, int64(e.Offset)); err != nil {
+					return err
+				}
+			} else if src == nil {
+				return fmt.Errorf("entry %s has no data", e.Name)
+			} else if err := copySection(wa, int64(e.Offset), src, int64(oldOffset[i]), int64(e.Length)); err != nil {
+				return err
+			}
+			padding := align(e.Length, p.BlockSize) - e.Length
+			if padding > 0 {
+				_, err := wa.WriteAt(make([]byte, padding), int64(e.Offset+e.Length))
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		if _, err = w.Write(buf); err != nil {
+			return err
+		}
+		for i, e := range p.Files {
+			// 共用数据的文件已由之前的文件写入
+			if shared[i] < 0 {
+				if err = p.writeEntry(w, e, src, oldOffset[i]); err != nil {
+					return err
+				}
+			}
+			if p.Progress != nil {
+				p.Progress(i+1, len(p.Files), e)
+			}
+		}
+	}
+	glog.V(2).Infof("Built pak: %d entries, header %d bytes, total %d bytes\n",
+		len(p.Files), headerLength, offset)
+	return nil
+}
+
+// writeEntry 顺序写入单个文件的数据并补齐到BlockSize
+func (p *Pak) writeEntry(w io.Writer, e *Entry, src io.ReaderAt, oldOffset uint32) error {
+	var err error
+	if e.Replace && e.Data != nil {
+		_, err = w.Write(e.Data)
+	} else if src == nil {
+		return fmt.Errorf("entry %s has no data", e.Name)
+	} else {
+		_, err = io.Copy(w, io.NewSectionReader(src, int64(oldOffset), int64(e.Length)))
+	}
+	if err != nil {
+		return err
+	}
+	if padding := align(e.Length, p.BlockSize) - e.Length; padding > 0 {
+		_, err = w.Write(make([]byte, padding))
+	}
+	return err
+}
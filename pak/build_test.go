@@ -0,0 +1,124 @@
+package pak
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-restruct/restruct"
+	"lucksystem/charset"
+)
+
+// writeTestPak 使用给定文件创建pak并写入临时文件
+func writeTestPak(t *testing.T, opts *NewOptions, names []string, datas [][]byte) string {
+	t.Helper()
+	restruct.EnableExprBeta()
+	p := New(opts)
+	for i, name := range names {
+		p.appendEntry(name, datas[i])
+	}
+	file := filepath.Join(t.TempDir(), "TEST.PAK")
+	out, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Write(out); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	out.Close()
+	return file
+}
+
+func TestBuildRoundTrip(t *testing.T) {
+	names := []string{"明朝32", "info32", "empty", "tail"}
+	datas := [][]byte{
+		bytes.Repeat([]byte{1}, 3000),
+		[]byte("info"),
+		{},
+		bytes.Repeat([]byte{7}, 64),
+	}
+	file := writeTestPak(t, &NewOptions{Coding: charset.ShiftJIS, BlockSize: 256, IDStart: 5, Named: true},
+		names, datas)
+
+	p, err := Open(file, charset.ShiftJIS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.FileCount != uint32(len(names)) || p.IDStart != 5 || p.BlockSize != 256 || p.Flags&FlagNamed == 0 {
+		t.Fatalf("unexpected header %+v", p.Header)
+	}
+	for i, name := range names {
+		e, err := p.Get(name)
+		if err != nil {
+			t.Fatalf("Get(%s): %v", name, err)
+		}
+		if e.ID != 5+i || e.Offset%256 != 0 {
+			t.Fatalf("%s: id %d offset %d", name, e.ID, e.Offset)
+		}
+		if !bytes.Equal(e.Data, datas[i]) {
+			t.Fatalf("%s: data mismatch", name)
+		}
+	}
+	fi, _ := os.Stat(file)
+	if fi.Size()%256 != 0 {
+		t.Fatalf("file size %d not aligned", fi.Size())
+	}
+}
+
+func TestBuildUnnamed(t *testing.T) {
+	datas := [][]byte{[]byte("a"), []byte("bb"), []byte("ccc")}
+	file := writeTestPak(t, &NewOptions{BlockSize: 1}, []string{"x", "y", "z"}, datas)
+
+	p, err := Open(file, charset.UTF_8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Flags&FlagNamed != 0 {
+		t.Fatal("unnamed pak has named flag")
+	}
+	for i := range datas {
+		e, err := p.GetByIndex(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(e.Data, datas[i]) {
+			t.Fatalf("entry %d data mismatch", i)
+		}
+	}
+}
+
+func TestBuildKeepsHeaderExtra(t *testing.T) {
+	datas := [][]byte{[]byte("a"), []byte("bb")}
+	p, err := Open(writeTestPak(t, &NewOptions{BlockSize: 16, Named: true}, []string{"x", "y"}, datas), charset.UTF_8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.HeaderExtra != nil {
+		t.Fatalf("header extra %v", p.HeaderExtra)
+	}
+	// 第二个值与重写后的 HeaderLength/BlockSize 相同，不能被当作偏移表开始
+	p.HeaderExtra = []byte{1, 2, 3, 4, 5, 0, 0, 0}
+	file := filepath.Join(t.TempDir(), "EXTRA.PAK")
+	out, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Build(out); err != nil {
+		t.Fatal(err)
+	}
+	out.Close()
+
+	q, err := Open(file, charset.UTF_8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(q.HeaderExtra, []byte{1, 2, 3, 4, 5, 0, 0, 0}) || q.OffsetPos != headerSize+12 {
+		t.Fatalf("header extra %v, offset table at %d", q.HeaderExtra, q.OffsetPos)
+	}
+	for i, name := range []string{"x", "y"} {
+		if e, err := q.Get(name); err != nil || !bytes.Equal(e.Data, datas[i]) {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package pak
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+)
+
+// DedupStats 去重结果
+type DedupStats struct {
+	Entries int   // 与之前文件共用数据的文件数
+	Saved   int64 // 节省的字节数，按BlockSize对齐计算
+}
+
+func (s DedupStats) String() string {
+	return fmt.Sprintf("%d duplicate entries share data, %d bytes saved", s.Entries, s.Saved)
+}
+
+// duplicates 查找内容相同的文件
+//
+//	Description 只对长度相同的文件计算sha256，未替换的文件从src中流式读取
+//	Receiver p *Pak
+//	Param src io.ReaderAt 原pak文件，可为nil
+//	Param oldOffset []uint32 文件在src中的位置
+//	Return []int 每个文件共用数据的第一个文件序号，不重复时为-1
+//	Return error
+func (p *Pak) duplicates(src io.ReaderAt, oldOffset []uint32) ([]int, error) {
+	shared := make([]int, len(p.Files))
+	counts := make(map[uint32]int)
+	for i, e := range p.Files {
+		shared[i] = -1
+		counts[e.Length]++
+	}
+	first := make(map[[sha256.Size]byte]int)
+	for i, e := range p.Files {
+		// 空文件不占空间；长度唯一的文件不可能重复
+		if e.Length == 0 || counts[e.Length] < 2 {
+			continue
+		}
+		h := sha256.New()
+		if e.Replace && e.Data != nil {
+			h.Write(e.Data)
+		} else if src == nil {
+			return nil, fmt.Errorf("entry %s has no data", e.Name)
+		} else if _, err := io.Copy(h, io.NewSectionReader(src, int64(oldOffset[i]), int64(e.Length))); err != nil {
+			return nil, err
+		}
+		var sum [sha256.Size]byte
+		copy(sum[:], h.Sum(nil))
+		if j, ok := first[sum]; ok {
+			shared[i] = j
+		} else {
+			first[sum] = i
+		}
+	}
+	return shared, nil
+}
+
+// sharesReplaced 是否有被替换的文件与其他文件共用数据
+//
+//	Description 此时原位写入会覆盖其他文件，需要完整重写
+func (p *Pak) sharesReplaced() bool {
+	used := make(map[uint32]int, len(p.Files))
+	for _, e := range p.Files {
+		if e.Length > 0 || e.Replace {
+			used[e.Offset]++
+		}
+	}
+	for _, e := range p.Files {
+		if e.Replace && used[e.Offset] > 1 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package pak
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-restruct/restruct"
+	"lucksystem/charset"
+)
+
+func TestBuildDedup(t *testing.T) {
+	restruct.EnableExprBeta()
+	blank := make([]byte, 100)
+	cg := bytes.Repeat([]byte{3}, 70)
+	names := []string{"blank1", "cg", "blank2", "other", "blank3", "cg_copy", "empty"}
+	datas := [][]byte{blank, cg, blank, bytes.Repeat([]byte{3}, 100), blank, cg, {}}
+
+	p := New(&NewOptions{BlockSize: 32, Named: true})
+	for i, name := range names {
+		p.appendEntry(name, datas[i])
+	}
+	p.Dedup = true
+	file := filepath.Join(t.TempDir(), "TEST.PAK")
+	out, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Write(out); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	out.Close()
+	if p.DedupStats.Entries != 3 || p.DedupStats.Saved != 128+128+96 {
+		t.Fatalf("stats = %+v", p.DedupStats)
+	}
+
+	loaded, err := Open(file, charset.UTF_8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problems := loaded.Verify(); len(problems) != 0 {
+		t.Fatalf("unexpected problems: %v", problems)
+	}
+	if loaded.Files[0].Offset != loaded.Files[2].Offset || loaded.Files[1].Offset != loaded.Files[5].Offset {
+		t.Fatal("duplicates do not share an offset")
+	}
+	for i, name := range names {
+		e, err := loaded.Get(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(e.Data, datas[i]) {
+			t.Errorf("%s: data mismatch", name)
+		}
+	}
+
+	// 替换共用数据的文件时不能覆盖其他文件
+	if err = loaded.Set("blank2", bytes.NewReader([]byte("changed"))); err != nil {
+		t.Fatal(err)
+	}
+	file2 := filepath.Join(t.TempDir(), "TEST2.PAK")
+	out, err = os.Create(file2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = loaded.Write(out); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	out.Close()
+	rewritten, err := Open(file2, charset.UTF_8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range names {
+		want := datas[i]
+		if name == "blank2" {
+			want = []byte("changed")
+		}
+		if e, _ := rewritten.Get(name); e == nil || !bytes.Equal(e.Data, want) {
+			t.Errorf("%s: data mismatch after replace", name)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package pak
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// FileType pak子文件的内容类型
+type FileType string
+
+const (
+	TypeUnknown FileType = "unknown"
+	TypeCZ0     FileType = "cz0"
+	TypeCZ1     FileType = "cz1"
+	TypeCZ2     FileType = "cz2"
+	TypeCZ3     FileType = "cz3"
+	TypeCZ4     FileType = "cz4"
+	TypeMVT     FileType = "mvt"
+	TypeOggPak  FileType = "oggpak"
+	TypeOgg     FileType = "ogg"
+	TypeScript  FileType = "script"
+)
+
+// DetectHeaderSize DetectType 判断类型时需要的最少数据量
+const DetectHeaderSize = 16
+
+// DetectType 根据文件头判断子文件类型
+//
+//	Description 图像、视频、音频通过magic判断；脚本根据CodeLine的长度链判断，
+//	  需要完整数据，只有文件头时不会判断为脚本
+//	Param data []byte
+//	Return FileType
+func DetectType(data []byte) FileType {
+	switch {
+	case len(data) >= 4 && data[0] == 'C' && data[1] == 'Z' && data[3] == 0:
+		switch data[2] {
+		case '0':
+			return TypeCZ0
+		case '1':
+			return TypeCZ1
+		case '2':
+			return TypeCZ2
+		case '3':
+			return TypeCZ3
+		case '4':
+			return TypeCZ4
+		}
+	case bytes.HasPrefix(data, []byte{'M', 'V', 'T', 0}):
+		return TypeMVT
+	case bytes.HasPrefix(data, []byte("OggPak")):
+		return TypeOggPak
+	case bytes.HasPrefix(data, []byte("OggS")):
+		return TypeOgg
+	case isScript(data):
+		return TypeScript
+	}
+	return TypeUnknown
+}
+
+// isScript 判断数据是否由连续的CodeLine组成
+//
+//	Description 与 game.isValidScript 相同，每行至少4字节 (Len + Opcode + FixedFlag)，
+//	  此外要求按 Len 向上对齐2 逐行跳转后恰好到达数据末尾，允许末尾的0填充
+func isScript(data []byte) bool {
+	pos := 0
+	lines := 0
+	for pos+2 <= len(data) {
+		size := int(binary.LittleEndian.Uint16(data[pos:]))
+		if size == 0 && lines > 0 && allZero(data[pos:]) {
+			// 末尾的0填充
+			return true
+		}
+		if size < 4 {
+			return false
+		}
+		pos += (size + 1) &^ 1
+		lines++
+	}
+	return lines > 0 && pos == len(data)
+}
+
+func allZero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Ext 类型对应的文件扩展名，未知类型返回空
+func (t FileType) Ext() string {
+	if t == TypeUnknown || len(t) == 0 {
+		return ""
+	}
+	return "." + string(t)
+}
@@ -0,0 +1,30 @@
+package pak
+
+import (
+	"testing"
+)
+
+func TestDetectType(t *testing.T) {
+	cases := []struct {
+		data []byte
+		want FileType
+	}{
+		{[]byte{'C', 'Z', '3', 0, 28, 0, 0, 0}, TypeCZ3},
+		{[]byte{'C', 'Z', '2', 0}, TypeCZ2},
+		{[]byte{'C', 'Z', '9', 0}, TypeUnknown},
+		{[]byte{'M', 'V', 'T', 0, 1, 2}, TypeMVT},
+		{[]byte("OggPak\x00"), TypeOggPak},
+		{[]byte("OggS\x00\x02"), TypeOgg},
+		// 两行CodeLine：Len=5(对齐到6)、Len=4
+		{[]byte{5, 0, 1, 0, 9, 0, 4, 0, 2, 0}, TypeScript},
+		{[]byte{4, 0, 1, 0, 0, 0, 0, 0}, TypeScript},
+		{[]byte{3, 0, 1, 0}, TypeUnknown},
+		{[]byte{4, 0, 1, 0, 9}, TypeUnknown},
+		{nil, TypeUnknown},
+	}
+	for i, c := range cases {
+		if got := DetectType(c.data); got != c.want {
+			t.Errorf("case %d: DetectType(%x) = %s, want %s", i, c.data, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package pak
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/golang/glog"
+)
+
+// Add 在pak末尾添加新文件
+//
+//	Description 添加后FileCount、ID与文件名表会重新计算，Write时完整重写pak
+//	Receiver p *Pak
+//	Param name string 新文件名，无文件名表的pak中会被替换为序号
+//	Param r io.Reader
+//	Return error
+func (p *Pak) Add(name string, r io.Reader) error {
+	if p.Flags&FlagNamed != 0 {
+		if len(name) == 0 {
+			return fmt.Errorf("empty entry name")
+		}
+		if p.CheckName(name) {
+			return fmt.Errorf("entry %s already exists", name)
+		}
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	e := p.appendEntry(name, data)
+	p.Rewrite = true
+	glog.V(4).Infof("Add %s: id %d, length %d\n", e.Name, e.ID, e.Length)
+	return nil
+}
+
+// Insert 在指定位置插入新文件
+//
+//	Description 之后文件的ID依次后移，index为len(Files)时同 Add
+//	Receiver p *Pak
+//	Param index int 插入后所在的位置
+//	Param name string
+//	Param r io.Reader
+//	Return error
+func (p *Pak) Insert(index int, name string, r io.Reader) error {
+	if index < 0 || index > len(p.Files) {
+		return fmt.Errorf("insert position %d out of range", index)
+	}
+	if err := p.Add(name, r); err != nil {
+		return err
+	}
+	e := p.Files[len(p.Files)-1]
+	copy(p.Files[index+1:], p.Files[index:len(p.Files)-1])
+	p.Files[index] = e
+	p.reindex()
+	return nil
+}
+
+// Remove 删除pak中的文件
+//
+//	Description 之后文件的ID依次前移
+//	Receiver p *Pak
+//	Param name string
+//	Return error
+func (p *Pak) Remove(name string) error {
+	id, has := p.NameMap[name]
+	if !has {
+		return notFound("%s", name)
+	}
+	index := id - int(p.IDStart)
+	p.Files = append(p.Files[:index], p.Files[index+1:]...)
+	p.reindex()
+	p.Rewrite = true
+	glog.V(4).Infof("Remove %s: id %d\n", name, id)
+	return nil
+}
+
+// Rename 重命名pak中的文件
+//
+//	Description 仅支持有文件名表的pak
+//	Receiver p *Pak
+//	Param oldName string
+//	Param newName string
+//	Return error
+func (p *Pak) Rename(oldName, newName string) error {
+	if p.Flags&FlagNamed == 0 {
+		return fmt.Errorf("pak has no name table")
+	}
+	id, has := p.NameMap[oldName]
+	if !has {
+		return notFound("%s", oldName)
+	}
+	if len(newName) == 0 {
+		return fmt.Errorf("empty entry name")
+	}
+	if p.CheckName(newName) {
+		return fmt.Errorf("entry %s already exists", newName)
+	}
+	p.Files[id-int(p.IDStart)].Name = newName
+	delete(p.NameMap, oldName)
+	p.NameMap[newName] = id
+	p.Rewrite = true
+	glog.V(4).Infof("Rename %s -> %s: id %d\n", oldName, newName, id)
+	return nil
+}
+
+// reindex 重新计算FileCount、ID与NameMap
+func (p *Pak) reindex() {
+	named := p.Flags&FlagNamed != 0
+	p.FileCount = uint32(len(p.Files))
+	p.NameMap = make(map[string]int, len(p.Files))
+	for i, e := range p.Files {
+		if !named {
+			e.Name = strconv.Itoa(i)
+		}
+		e.ID = int(p.IDStart) + i
+		p.NameMap[e.Name] = e.ID
+	}
+}
@@ -0,0 +1,97 @@
+package pak
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"lucksystem/charset"
+)
+
+func TestAddRemoveRename(t *testing.T) {
+	file := writeTestPak(t, &NewOptions{BlockSize: 16, IDStart: 10, Named: true},
+		[]string{"a", "b", "c"},
+		[][]byte{[]byte("aaaa"), []byte("bbbbbbbbbbbbbbbbbbbb"), []byte("c")})
+
+	p, err := Open(file, charset.UTF_8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Add("a", strings.NewReader("dup")); err == nil {
+		t.Fatal("expected duplicate name to fail")
+	}
+	if err := p.Add("d", strings.NewReader("dddd")); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Remove("b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Rename("c", "cc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Rename("a", "d"); err == nil {
+		t.Fatal("expected rename onto existing name to fail")
+	}
+	if err := p.Insert(1, "ab", strings.NewReader("ab")); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Insert(9, "x", strings.NewReader("x")); err == nil {
+		t.Fatal("expected out of range insert to fail")
+	}
+
+	out := filepath.Join(t.TempDir(), "OUT.PAK")
+	fs, err := os.Create(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Write(fs); err != nil {
+		t.Fatal(err)
+	}
+	fs.Close()
+
+	q, err := Open(out, charset.UTF_8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"a": "aaaa", "ab": "ab", "cc": "c", "d": "dddd"}
+	if q.FileCount != uint32(len(want)) {
+		t.Fatalf("FileCount = %d", q.FileCount)
+	}
+	for i, name := range []string{"a", "ab", "cc", "d"} {
+		e, err := q.Get(name)
+		if err != nil {
+			t.Fatalf("Get(%s): %v", name, err)
+		}
+		if e.ID != 10+i {
+			t.Fatalf("%s: id %d", name, e.ID)
+		}
+		if !bytes.Equal(e.Data, []byte(want[name])) {
+			t.Fatalf("%s: data %q", name, e.Data)
+		}
+	}
+}
+
+func TestRemoveUnnamed(t *testing.T) {
+	file := writeTestPak(t, &NewOptions{BlockSize: 4}, []string{"", "", ""},
+		[][]byte{[]byte("0"), []byte("1"), []byte("2")})
+
+	p, err := Open(file, charset.UTF_8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Remove("0"); err != nil {
+		t.Fatal(err)
+	}
+	e, err := p.Get("0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(e.Data) != "1" {
+		t.Fatalf("index 0 after remove = %q", e.Data)
+	}
+	if err = p.Rename("0", "x"); err == nil {
+		t.Fatal("expected rename on unnamed pak to fail")
+	}
+}
@@ -0,0 +1,53 @@
+package pak
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrEntryNotFound 文件名、ID或序号不存在
+	ErrEntryNotFound = errors.New("entry not found")
+	// ErrBadHeader 文件头或偏移表、文件名表损坏
+	ErrBadHeader = errors.New("bad pak header")
+)
+
+// ImportFailure Import中单行或单个文件导入失败的原因
+type ImportFailure struct {
+	Line int    // 列表中的行号，从1开始；dir模式为0
+	Text string // 列表中的行或文件名
+	Err  error
+}
+
+func (f ImportFailure) String() string {
+	if f.Line > 0 {
+		return fmt.Sprintf("line %d: %s: %v", f.Line, f.Text, f.Err)
+	}
+	return fmt.Sprintf("%s: %v", f.Text, f.Err)
+}
+
+// ImportError Import导入结束后仍有失败项时返回，其余项已经导入
+type ImportError struct {
+	Imported int
+	Failures []ImportFailure
+}
+
+func (e *ImportError) Error() string {
+	lines := make([]string, 0, len(e.Failures)+1)
+	lines = append(lines, fmt.Sprintf("%d imported, %d failed:", e.Imported, len(e.Failures)))
+	for _, f := range e.Failures {
+		lines = append(lines, "  "+f.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// badHeader 包装 ErrBadHeader
+func badHeader(format string, a ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrBadHeader, fmt.Sprintf(format, a...))
+}
+
+// notFound 包装 ErrEntryNotFound
+func notFound(format string, a ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrEntryNotFound, fmt.Sprintf(format, a...))
+}
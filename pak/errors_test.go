@@ -0,0 +1,63 @@
+package pak
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"lucksystem/charset"
+)
+
+func TestOpenBadHeader(t *testing.T) {
+	dir := t.TempDir()
+	cases := map[string][]byte{
+		"short":      {1, 2},
+		"length":     {0xff, 0xff, 0, 0, 0, 0, 0, 0},
+		"block size": append([]byte{40, 0, 0, 0, 1}, make([]byte, 35)...),
+	}
+	for name, data := range cases {
+		file := filepath.Join(dir, strings.Replace(name, " ", "_", -1))
+		os.WriteFile(file, data, 0666)
+		if _, err := Open(file, charset.UTF_8); !errors.Is(err, ErrBadHeader) {
+			t.Errorf("%s: Open error = %v, want ErrBadHeader", name, err)
+		}
+	}
+}
+
+func TestImportListReport(t *testing.T) {
+	file := writeTestPak(t, &NewOptions{BlockSize: 16, Named: true},
+		[]string{"a", "b"}, [][]byte{[]byte("aaaa"), []byte("bbbb")})
+	p, err := Open(file, charset.UTF_8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.Get("missing"); !errors.Is(err, ErrEntryNotFound) {
+		t.Errorf("Get error = %v, want ErrEntryNotFound", err)
+	}
+
+	dir := t.TempDir()
+	newA := filepath.Join(dir, "a")
+	os.WriteFile(newA, []byte("AAAA"), 0666)
+	list := strings.Join([]string{
+		"name:a," + newA,
+		"name:missing," + newA,
+		"bad line",
+		"name:b," + filepath.Join(dir, "nofile"),
+	}, "\n")
+	err = p.Import(strings.NewReader(list), "list", nil)
+	var report *ImportError
+	if !errors.As(err, &report) {
+		t.Fatalf("Import error = %v, want *ImportError", err)
+	}
+	if report.Imported != 1 || len(report.Failures) != 3 {
+		t.Fatalf("report = %v", report)
+	}
+	if report.Failures[0].Line != 2 || !errors.Is(report.Failures[0].Err, ErrEntryNotFound) {
+		t.Errorf("failure = %v", report.Failures[0])
+	}
+	if e, _ := p.Get("a"); string(e.Data) != "AAAA" {
+		t.Errorf("a = %q", e.Data)
+	}
+}
@@ -0,0 +1,176 @@
+package pak
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+// ConvertFunc 将子文件转换为通用格式（CZ→PNG，MVT→WebM，OggPak→ogg等），写入file同目录下
+//
+//	Description 只对 CZ、MVT、OggPak 类型调用，file为已写出的原始数据文件，
+//	  返回转换后的文件。解码panic时只跳过当前文件
+type ConvertFunc func(t FileType, data []byte, file string) (files []string, err error)
+
+// ExtractOptions 解包参数
+type ExtractOptions struct {
+	Detect  bool        // 根据文件头添加扩展名
+	Convert ConvertFunc // 非nil时转换为通用格式，包含Detect
+	Layout  bool        // 清单中记录完整结构，用于 BuildManifest 逐字节重建
+}
+
+// Extract 按内容解包全部文件
+//
+//	Description 使用 Pak.Jobs 个goroutine流式写出子文件，只有需要转换的文件会载入内存。输出目录中写入 ManifestName 清单，
+//	  w 中写入与 Export mode=="all" 相同格式的列表，可直接用于 Import mode=="list"
+//	Receiver p *Pak
+//	Param w io.Writer 列表输出，可为nil
+//	Param dir string 输出目录
+//	Param opts *ExtractOptions
+//	Return *Manifest
+//	Return error
+func (p *Pak) Extract(w io.Writer, dir string, opts *ExtractOptions) (*Manifest, error) {
+	if opts == nil {
+		opts = &ExtractOptions{}
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	fsys, err := NewFS(p)
+	if err != nil {
+		return nil, err
+	}
+	defer fsys.Close()
+
+	m := &Manifest{
+		Pak:     filepath.Base(p.FileName),
+		Charset: p.Coding,
+		Entries: make([]ManifestEntry, len(p.Files)),
+	}
+	if opts.Layout {
+		if m.Layout, err = p.layout(); err != nil {
+			return nil, err
+		}
+	}
+	err = p.parallel(p.allIndexes(), func(i int) error {
+		me, err := p.extractEntry(fsys, i, dir, opts)
+		if err != nil {
+			return err
+		}
+		m.Entries[i] = *me
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if w != nil {
+		for i, e := range p.Files {
+			file := filepath.Join(dir, filepath.FromSlash(m.Entries[i].File))
+			line := fmt.Sprintf("name:%s,%s\n", e.Name, file)
+			if len(e.Name) == 0 {
+				line = fmt.Sprintf("id:%d,%s\n", e.ID, file)
+			}
+			if _, err = io.WriteString(w, line); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err = m.Save(filepath.Join(dir, ManifestName)); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// extractEntry 解包单个文件
+//
+//	Description 只有需要转换时才载入全部数据，否则流式写出
+func (p *Pak) extractEntry(fsys *FS, i int, dir string, opts *ExtractOptions) (*ManifestEntry, error) {
+	e := p.Files[i]
+	r, err := fsys.reader(e)
+	if err != nil {
+		return nil, err
+	}
+	name := e.Name
+	if len(name) == 0 {
+		name = strconv.Itoa(e.ID)
+	}
+	me := &ManifestEntry{Index: i, ID: e.ID, Name: e.Name, Type: TypeUnknown}
+	if opts.Detect || opts.Convert != nil {
+		if me.Type, err = detectReader(r); err != nil {
+			return nil, err
+		}
+		if ext := me.Type.Ext(); len(ext) > 0 && !strings.EqualFold(filepath.Ext(name), ext) {
+			name += ext
+		}
+	}
+	file := filepath.Join(dir, name)
+	me.File = filepath.ToSlash(name)
+
+	var data []byte
+	convert := opts.Convert != nil && convertible(me.Type)
+	if convert {
+		data = make([]byte, r.Size())
+		if _, err = r.ReadAt(data, 0); err != nil && err != io.EOF {
+			return nil, err
+		}
+		if err = os.WriteFile(file, data, 0666); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Layout {
+		h := sha256.New()
+		if convert {
+			h.Write(data)
+		} else if err = writeFile(file, io.TeeReader(r, h)); err != nil {
+			return nil, err
+		}
+		me.Offset, me.Length, me.SHA256 = e.Offset, e.Length, hex.EncodeToString(h.Sum(nil))
+	} else if !convert {
+		if err = writeFile(file, r); err != nil {
+			return nil, err
+		}
+	}
+	if convert {
+		converted, err := convertEntry(opts.Convert, me.Type, data, file)
+		if err != nil {
+			glog.Warningf("%s: %v\n", me.File, err)
+			me.Error = err.Error()
+		}
+		for _, c := range converted {
+			rel, _ := filepath.Rel(dir, c)
+			me.Converted = append(me.Converted, filepath.ToSlash(rel))
+		}
+	}
+	return me, nil
+}
+
+// convertible 是否支持转换为通用格式
+func convertible(t FileType) bool {
+	switch t {
+	case TypeCZ0, TypeCZ1, TypeCZ2, TypeCZ3, TypeCZ4, TypeMVT, TypeOggPak:
+		return true
+	}
+	return false
+}
+
+// convertEntry 调用convert转换子文件，panic作为错误返回
+func convertEntry(convert ConvertFunc, t FileType, data []byte, file string) (files []string, err error) {
+	defer func() {
+		// 损坏的数据可能导致解码panic，只跳过当前文件
+		if r := recover(); r != nil {
+			err = fmt.Errorf("convert %s: %v", t, r)
+		}
+	}()
+	return convert(t, data, file)
+}
@@ -0,0 +1,84 @@
+package pak
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-restruct/restruct"
+	"lucksystem/charset"
+)
+
+// testConvert MVT写出一个文件，OggPak写出两个文件，CZ触发panic
+func testConvert(t FileType, data []byte, file string) ([]string, error) {
+	base := strings.TrimSuffix(file, t.Ext())
+	switch t {
+	case TypeMVT:
+		return []string{base + ".webm"}, os.WriteFile(base+".webm", data[6:], 0666)
+	case TypeOggPak:
+		files := []string{base + "_0.ogg", base + "_1.ogg"}
+		for _, f := range files {
+			if err := os.WriteFile(f, nil, 0666); err != nil {
+				return nil, err
+			}
+		}
+		return files, nil
+	}
+	panic("corrupted")
+}
+
+func TestExtractConvert(t *testing.T) {
+	restruct.EnableExprBeta()
+	webm := []byte{0x1A, 0x45, 0xDF, 0xA3, 1, 2, 3}
+	mvt := append([]byte{'M', 'V', 'T', 0, 9, 9}, webm...)
+	oggPak := append([]byte("OggPak\x00"),
+		0x44, 0xAC, 0, 0, 4, 0, 0, 0, 'O', 'g', 'g', 'S',
+		0x44, 0xAC, 0, 0, 2, 0, 0, 0, 'O', 'g')
+	file := writeTestPak(t, &NewOptions{BlockSize: 16, Named: true},
+		[]string{"op", "voice", "data.bin", "bad"},
+		[][]byte{mvt, oggPak, []byte("plain"), []byte("CZ3\x00broken")})
+
+	p, err := Open(file, charset.UTF_8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	list := &bytes.Buffer{}
+	m, err := p.Extract(list, dir, &ExtractOptions{Convert: testConvert})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		file      string
+		typ       FileType
+		converted []string
+	}{
+		{"op.mvt", TypeMVT, []string{"op.webm"}},
+		{"voice.oggpak", TypeOggPak, []string{"voice_0.ogg", "voice_1.ogg"}},
+		{"data.bin", TypeUnknown, nil},
+		{"bad.cz3", TypeCZ3, nil},
+	}
+	for i, w := range want {
+		e := m.Entries[i]
+		if e.File != w.file || e.Type != w.typ || strings.Join(e.Converted, ",") != strings.Join(w.converted, ",") {
+			t.Errorf("entry %d = %+v", i, e)
+		}
+		for _, c := range w.converted {
+			if _, err = os.Stat(filepath.Join(dir, c)); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "op.webm"))
+	if !bytes.Equal(data, webm) {
+		t.Errorf("webm = %x", data)
+	}
+	if m.Entries[3].Error != "convert cz3: corrupted" {
+		t.Errorf("error = %q", m.Entries[3].Error)
+	}
+	if !strings.Contains(list.String(), "name:voice,"+filepath.Join(dir, "voice.oggpak")) {
+		t.Errorf("list = %s", list)
+	}
+}
@@ -0,0 +1,245 @@
+package pak
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"time"
+
+	"lucksystem/charset"
+)
+
+// FS 以 io/fs 的方式访问pak中的文件
+//
+//	Description 子文件按需通过 io.SectionReader 读取，不会载入全部数据。
+//	  pak中的文件均位于根目录"."下，已被替换的文件返回替换后的数据
+type FS struct {
+	pak     *Pak
+	file    *os.File
+	modTime time.Time
+}
+
+var (
+	_ fs.FS         = (*FS)(nil)
+	_ fs.ReadDirFS  = (*FS)(nil)
+	_ fs.StatFS     = (*FS)(nil)
+	_ fs.ReadFileFS = (*FS)(nil)
+)
+
+// NewFS 使用已载入的pak创建FS
+//
+//	Description 使用完毕后需要调用 Close
+//	Param p *Pak
+//	Return *FS
+//	Return error
+func NewFS(p *Pak) (*FS, error) {
+	fsys := &FS{pak: p}
+	if len(p.FileName) > 0 {
+		f, err := os.Open(p.FileName)
+		if err != nil {
+			return nil, err
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		fsys.file = f
+		fsys.modTime = fi.ModTime()
+	}
+	return fsys, nil
+}
+
+// OpenFS 载入pak文件并创建FS
+func OpenFS(filename string, coding charset.Charset) (*FS, error) {
+	p, err := Open(filename, coding)
+	if err != nil {
+		return nil, err
+	}
+	return NewFS(p)
+}
+
+// Pak 取得FS使用的pak
+func (fsys *FS) Pak() *Pak {
+	return fsys.pak
+}
+
+// Close 关闭pak文件
+func (fsys *FS) Close() error {
+	if fsys.file == nil {
+		return nil
+	}
+	return fsys.file.Close()
+}
+
+// lookup 通过文件名查找子文件
+func (fsys *FS) lookup(op, name string) (*Entry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	id, ok := fsys.pak.NameMap[name]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return fsys.pak.Files[id-int(fsys.pak.IDStart)], nil
+}
+
+// reader 取得子文件数据的读取器
+func (fsys *FS) reader(e *Entry) (*io.SectionReader, error) {
+	if e.Data != nil && (e.Offset == 0 || e.Replace) {
+		return io.NewSectionReader(bytes.NewReader(e.Data), 0, int64(len(e.Data))), nil
+	}
+	if fsys.file == nil {
+		return nil, fs.ErrNotExist
+	}
+	return io.NewSectionReader(fsys.file, int64(e.Offset), int64(e.Length)), nil
+}
+
+// Open 实现 fs.FS
+func (fsys *FS) Open(name string) (fs.File, error) {
+	if name == "." {
+		return &rootDir{fsys: fsys}, nil
+	}
+	e, err := fsys.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	r, err := fsys.reader(e)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &entryFile{SectionReader: r, info: fsys.info(e)}, nil
+}
+
+// ReadFile 实现 fs.ReadFileFS
+func (fsys *FS) ReadFile(name string) ([]byte, error) {
+	e, err := fsys.lookup("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	r, err := fsys.reader(e)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: err}
+	}
+	data := make([]byte, r.Size())
+	if _, err = r.ReadAt(data, 0); err != nil && err != io.EOF {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: err}
+	}
+	return data, nil
+}
+
+// Stat 实现 fs.StatFS
+func (fsys *FS) Stat(name string) (fs.FileInfo, error) {
+	if name == "." {
+		return &rootInfo{modTime: fsys.modTime}, nil
+	}
+	e, err := fsys.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return fsys.info(e), nil
+}
+
+// ReadDir 实现 fs.ReadDirFS，按文件名排序
+func (fsys *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name != "." {
+		if _, err := fsys.lookup("readdir", name); err != nil {
+			return nil, err
+		}
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	return fsys.entries(), nil
+}
+
+func (fsys *FS) entries() []fs.DirEntry {
+	list := make([]fs.DirEntry, 0, len(fsys.pak.Files))
+	for _, e := range fsys.pak.Files {
+		list = append(list, fsys.info(e))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name() < list[j].Name()
+	})
+	return list
+}
+
+func (fsys *FS) info(e *Entry) *entryInfo {
+	size := int64(e.Length)
+	if e.Data != nil && (e.Offset == 0 || e.Replace) {
+		size = int64(len(e.Data))
+	}
+	return &entryInfo{entry: e, size: size, modTime: fsys.modTime}
+}
+
+// entryInfo 子文件信息，同时实现 fs.FileInfo 与 fs.DirEntry
+type entryInfo struct {
+	entry   *Entry
+	size    int64
+	modTime time.Time
+}
+
+func (i *entryInfo) Name() string               { return i.entry.Name }
+func (i *entryInfo) Size() int64                { return i.size }
+func (i *entryInfo) Mode() fs.FileMode          { return 0444 }
+func (i *entryInfo) ModTime() time.Time         { return i.modTime }
+func (i *entryInfo) IsDir() bool                { return false }
+func (i *entryInfo) Sys() interface{}           { return i.entry }
+func (i *entryInfo) Type() fs.FileMode          { return 0 }
+func (i *entryInfo) Info() (fs.FileInfo, error) { return i, nil }
+
+// entryFile 打开的子文件，支持 Read、ReadAt、Seek
+type entryFile struct {
+	*io.SectionReader
+	info *entryInfo
+}
+
+func (f *entryFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *entryFile) Close() error               { return nil }
+
+// rootInfo 根目录信息
+type rootInfo struct {
+	modTime time.Time
+}
+
+func (i *rootInfo) Name() string       { return "." }
+func (i *rootInfo) Size() int64        { return 0 }
+func (i *rootInfo) Mode() fs.FileMode  { return fs.ModeDir | 0555 }
+func (i *rootInfo) ModTime() time.Time { return i.modTime }
+func (i *rootInfo) IsDir() bool        { return true }
+func (i *rootInfo) Sys() interface{}   { return nil }
+
+// rootDir 打开的根目录
+type rootDir struct {
+	fsys    *FS
+	entries []fs.DirEntry
+	offset  int
+	read    bool
+}
+
+func (d *rootDir) Stat() (fs.FileInfo, error) { return &rootInfo{modTime: d.fsys.modTime}, nil }
+func (d *rootDir) Close() error               { return nil }
+func (d *rootDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: ".", Err: fs.ErrInvalid}
+}
+
+// ReadDir 实现 fs.ReadDirFile
+func (d *rootDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		d.entries = d.fsys.entries()
+		d.read = true
+	}
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(rest) {
+		n = len(rest)
+	}
+	d.offset += n
+	return rest[:n], nil
+}
@@ -0,0 +1,62 @@
+package pak
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"lucksystem/charset"
+)
+
+func TestFS(t *testing.T) {
+	file := writeTestPak(t, &NewOptions{BlockSize: 32, Named: true},
+		[]string{"明朝32", "info32", "empty"},
+		[][]byte{[]byte("font image"), []byte("info"), {}})
+
+	p, err := Open(file, charset.UTF_8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Set("info32", strings.NewReader("replaced info")); err != nil {
+		t.Fatal(err)
+	}
+	fsys, err := NewFS(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fsys.Close()
+
+	if err = fstest.TestFS(fsys, "明朝32", "info32", "empty"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := fs.ReadFile(fsys, "明朝32")
+	if err != nil || string(data) != "font image" {
+		t.Fatalf("ReadFile = %q, %v", data, err)
+	}
+	data, err = fs.ReadFile(fsys, "info32")
+	if err != nil || string(data) != "replaced info" {
+		t.Fatalf("replaced ReadFile = %q, %v", data, err)
+	}
+	if _, err = fsys.Open("missing"); err == nil {
+		t.Fatal("expected missing entry to fail")
+	}
+	count := 0
+	err = fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			count++
+		}
+		return nil
+	})
+	if err != nil || count != 3 {
+		t.Fatalf("WalkDir visited %d files, %v", count, err)
+	}
+	for _, e := range p.Files {
+		if e.Name == "明朝32" && e.Data != nil {
+			t.Fatal("FS loaded entry data into memory")
+		}
+	}
+}
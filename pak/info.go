@@ -0,0 +1,107 @@
+package pak
+
+import (
+	"io"
+	"os"
+
+	"lucksystem/charset"
+)
+
+// maxScriptDetectSize 超过此大小的未知文件不再读取全部数据判断是否为脚本
+const maxScriptDetectSize = 4 << 20
+
+// Info pak结构信息，用于输出json
+type Info struct {
+	FileName  string          `json:"file_name"`
+	Coding    charset.Charset `json:"charset"`
+	Header    Header          `json:"header"`
+	Named     bool            `json:"named"`
+	OffsetPos int64           `json:"offset_pos"`
+	DataPos   int64           `json:"data_pos"`
+	Entries   []EntryInfo     `json:"entries"`
+}
+
+// EntryInfo 子文件信息
+type EntryInfo struct {
+	Index  int      `json:"index"`
+	ID     int      `json:"id"`
+	Name   string   `json:"name"`
+	Offset uint32   `json:"offset"`
+	Length uint32   `json:"length"`
+	Type   FileType `json:"type"`
+}
+
+// GetInfo 取得pak结构信息
+//
+//	Description 读取每个子文件的文件头判断类型，不会载入全部数据
+//	Receiver p *Pak
+//	Return *Info
+//	Return error
+func (p *Pak) GetInfo() (*Info, error) {
+	info := &Info{
+		FileName:  p.FileName,
+		Coding:    p.Coding,
+		Header:    p.Header,
+		Named:     p.Flags&FlagNamed != 0,
+		OffsetPos: p.OffsetPos,
+		DataPos:   p.DataPos,
+		Entries:   make([]EntryInfo, len(p.Files)),
+	}
+	var f *os.File
+	if len(p.FileName) > 0 {
+		var err error
+		f, err = os.Open(p.FileName)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+	}
+	for i, e := range p.Files {
+		t, err := p.detect(f, e)
+		if err != nil {
+			return nil, err
+		}
+		info.Entries[i] = EntryInfo{
+			Index:  i,
+			ID:     e.ID,
+			Name:   e.Name,
+			Offset: e.Offset,
+			Length: e.Length,
+			Type:   t,
+		}
+	}
+	return info, nil
+}
+
+// detect 判断子文件类型，不会载入全部数据
+func (p *Pak) detect(f *os.File, e *Entry) (FileType, error) {
+	if e.Data != nil && (e.Offset == 0 || e.Replace) {
+		return DetectType(e.Data), nil
+	}
+	if f == nil {
+		return TypeUnknown, nil
+	}
+	return detectReader(io.NewSectionReader(f, int64(e.Offset), int64(e.Length)))
+}
+
+// detectReader 读取文件头判断类型，可能是脚本时读取全部数据
+func detectReader(r *io.SectionReader) (FileType, error) {
+	size := r.Size()
+	if size > DetectHeaderSize {
+		size = DetectHeaderSize
+	}
+	data := make([]byte, size)
+	if _, err := r.ReadAt(data, 0); err != nil && err != io.EOF {
+		return TypeUnknown, err
+	}
+	// 脚本只能通过完整数据判断
+	t := DetectType(data)
+	if (t == TypeUnknown || t == TypeScript) && r.Size() > size && r.Size() <= maxScriptDetectSize {
+		data = make([]byte, r.Size())
+		if _, err := r.ReadAt(data, 0); err != nil && err != io.EOF {
+			return TypeUnknown, err
+		}
+		t = DetectType(data)
+	}
+	return t, nil
+}
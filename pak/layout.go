@@ -0,0 +1,349 @@
+package pak
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-restruct/restruct"
+	"github.com/golang/glog"
+	"lucksystem/charset"
+)
+
+// gapMergeSize 非0数据之间少于此长度的0会合并为一段
+const gapMergeSize = 8
+
+// Layout pak的完整结构
+//
+//	Description Header、偏移表位置、文件名表位置以及不属于任何结构的非0数据，
+//	  与 ManifestEntry 中的原始位置一起可以逐字节重建pak
+type Layout struct {
+	Header     Header `json:"header"`
+	OffsetPos  int64  `json:"offset_pos"`  // 偏移长度表位置
+	NameOffset uint32 `json:"name_offset"` // OffsetPos-4 处的值，有文件名表时为文件名表位置
+	FileSize   int64  `json:"file_size"`
+	Gaps       []Gap  `json:"gaps,omitempty"`
+}
+
+// Gap 不属于Header、偏移表、文件名表和文件数据的非0数据
+type Gap struct {
+	Offset int64  `json:"offset"`
+	Data   string `json:"data"` // hex
+}
+
+// span 文件中的一段区域
+type span struct {
+	start, end int64
+}
+
+// layout 读取pak的完整结构
+//
+//	Description 只读取未被任何结构覆盖的区域，不会读取文件数据
+//	Receiver p *Pak
+//	Return *Layout
+//	Return error
+func (p *Pak) layout() (*Layout, error) {
+	f, err := os.Open(p.FileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	l := &Layout{
+		Header:    p.Header,
+		OffsetPos: p.OffsetPos,
+		FileSize:  fi.Size(),
+	}
+	temp := make([]byte, 4)
+	if _, err = f.ReadAt(temp, p.OffsetPos-4); err != nil {
+		return nil, err
+	}
+	l.NameOffset = binary.LittleEndian.Uint32(temp)
+
+	covered := []span{
+		{0, headerSize},
+		{p.OffsetPos - 4, p.OffsetPos + 8*int64(len(p.Files))},
+	}
+	if p.Flags&FlagNamed != 0 {
+		size, err := p.nameTableSize()
+		if err != nil {
+			return nil, err
+		}
+		covered = append(covered, span{int64(l.NameOffset), int64(l.NameOffset) + size})
+	}
+	for _, e := range p.Files {
+		covered = append(covered, span{int64(e.Offset), int64(e.Offset) + int64(e.Length)})
+	}
+	for _, s := range uncovered(covered, l.FileSize) {
+		data := make([]byte, s.end-s.start)
+		if _, err = f.ReadAt(data, s.start); err != nil {
+			return nil, err
+		}
+		l.Gaps = append(l.Gaps, nonZeroGaps(data, s.start)...)
+	}
+	return l, nil
+}
+
+// nameTableSize 编码后的文件名表大小
+func (p *Pak) nameTableSize() (int64, error) {
+	size := int64(0)
+	for _, e := range p.Files {
+		name, err := charset.UTF8To(p.Coding, []byte(e.Name))
+		if err != nil {
+			return 0, err
+		}
+		size += int64(len(name)) + 1
+	}
+	return size, nil
+}
+
+// uncovered 取得[0,size)中未被覆盖的区域
+func uncovered(covered []span, size int64) []span {
+	sort.Slice(covered, func(i, j int) bool {
+		return covered[i].start < covered[j].start
+	})
+	list := make([]span, 0)
+	pos := int64(0)
+	for _, s := range covered {
+		if s.start > pos {
+			list = append(list, span{pos, s.start})
+		}
+		if s.end > pos {
+			pos = s.end
+		}
+	}
+	if pos < size {
+		list = append(list, span{pos, size})
+	}
+	return list
+}
+
+// nonZeroGaps 取得数据中的非0段
+func nonZeroGaps(data []byte, offset int64) []Gap {
+	gaps := make([]Gap, 0)
+	start, end := -1, -1
+	flush := func() {
+		if start >= 0 {
+			gaps = append(gaps, Gap{Offset: offset + int64(start), Data: hex.EncodeToString(data[start:end])})
+		}
+	}
+	for i, b := range data {
+		if b == 0 {
+			continue
+		}
+		if start >= 0 && i-end >= gapMergeSize {
+			flush()
+			start = -1
+		}
+		if start < 0 {
+			start = i
+		}
+		end = i + 1
+	}
+	flush()
+	return gaps
+}
+
+// segment 重建时写入的一段数据
+type segment struct {
+	offset int64
+	data   []byte
+	file   string // data为nil时从文件读取
+	length int64
+}
+
+// BuildManifest 按清单重建pak
+//
+//	Description 清单需要包含 Layout。未修改的文件写回原位置，文件名表、Header与间隙数据原样写入，
+//	  全部未修改时与原pak逐字节相同。修改后的文件若不超过原来的块且不与其他文件共用，写回原位置，
+//	  否则追加到文件末尾
+//	Param w io.Writer
+//	Param m *Manifest
+//	Param dir string 清单中文件的相对目录
+//	Return error
+func BuildManifest(w io.Writer, m *Manifest, dir string) error {
+	l := m.Layout
+	if l == nil {
+		return errors.New("manifest has no layout, extract it again with --manifest")
+	}
+	h := l.Header
+	if h.BlockSize == 0 {
+		return errors.New("manifest layout has no block size")
+	}
+	named := h.Flags&FlagNamed != 0
+	h.FileCount = uint32(len(m.Entries))
+
+	// 1. Header区域
+	header := make([]byte, h.HeaderLength)
+	for _, g := range l.Gaps {
+		if g.Offset < int64(h.HeaderLength) {
+			data, err := hex.DecodeString(g.Data)
+			if err != nil {
+				return err
+			}
+			copy(header[g.Offset:], data)
+		}
+	}
+	packed, err := restruct.Pack(binary.LittleEndian, &h)
+	if err != nil {
+		return err
+	}
+	copy(header, packed)
+	tableEnd := l.OffsetPos + 8*int64(len(m.Entries))
+	if tableEnd > int64(h.HeaderLength) || (named && tableEnd > int64(l.NameOffset) && l.OffsetPos < int64(l.NameOffset)) {
+		return fmt.Errorf("%d entries do not fit in the original offset table, use pak create", len(m.Entries))
+	}
+	if l.OffsetPos-4 >= headerSize {
+		binary.LittleEndian.PutUint32(header[l.OffsetPos-4:], l.NameOffset)
+	}
+	if named {
+		names := &bytes.Buffer{}
+		for _, e := range m.Entries {
+			name, err := charset.UTF8To(m.Charset, []byte(e.Name))
+			if err != nil {
+				return err
+			}
+			names.WriteString(name)
+			names.WriteByte(0)
+		}
+		if int64(l.NameOffset)+int64(names.Len()) > int64(h.HeaderLength) {
+			return errors.New("name table does not fit in the original header, use pak create")
+		}
+		copy(header[l.NameOffset:], names.Bytes())
+	}
+
+	// 2. 文件位置
+	used := make(map[uint32]int) // 原位置被多少文件使用
+	for _, e := range m.Entries {
+		if e.Offset > 0 {
+			used[e.Offset]++
+		}
+	}
+	segments := []segment{{offset: 0, data: header, length: int64(len(header))}}
+	slots := make([]span, 0) // 修改后写回原位置的块，其中的间隙数据不再写入
+	end := int64(align(uint32(l.FileSize), h.BlockSize))
+	relocated := 0
+	for i, e := range m.Entries {
+		file := filepath.Join(dir, filepath.FromSlash(e.File))
+		fi, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		size := fi.Size()
+		offset := int64(e.Offset)
+		if e.Offset == 0 || !sameFile(file, size, e) {
+			slot := int64(align(e.Length, h.BlockSize))
+			if e.Offset == 0 || size > slot || used[e.Offset] > 1 {
+				offset = end
+				end = int64(align(uint32(end+size), h.BlockSize))
+				relocated++
+			} else {
+				slots = append(slots, span{offset, offset + slot})
+			}
+		}
+		binary.LittleEndian.PutUint32(header[l.OffsetPos+int64(i*8):], uint32(offset)/h.BlockSize)
+		binary.LittleEndian.PutUint32(header[l.OffsetPos+int64(i*8+4):], uint32(size))
+		segments = append(segments, segment{offset: offset, file: file, length: size})
+	}
+	for _, g := range l.Gaps {
+		if g.Offset < int64(h.HeaderLength) || inSpans(slots, g.Offset) {
+			continue
+		}
+		data, err := hex.DecodeString(g.Data)
+		if err != nil {
+			return err
+		}
+		segments = append(segments, segment{offset: g.Offset, data: data, length: int64(len(data))})
+	}
+	size := l.FileSize
+	if relocated > 0 {
+		size = end
+	}
+
+	// 3. 按位置顺序写入，共用位置的文件只写入一次
+	sort.SliceStable(segments, func(i, j int) bool {
+		return segments[i].offset < segments[j].offset
+	})
+	pos := int64(0)
+	for _, s := range segments {
+		if s.offset+s.length <= pos {
+			continue
+		}
+		if s.offset > pos {
+			if err = writeZero(w, s.offset-pos); err != nil {
+				return err
+			}
+			pos = s.offset
+		}
+		data := s.data
+		if data == nil {
+			if data, err = os.ReadFile(s.file); err != nil {
+				return err
+			}
+		}
+		if _, err = w.Write(data[pos-s.offset:]); err != nil {
+			return err
+		}
+		pos = s.offset + s.length
+	}
+	if pos < size {
+		if err = writeZero(w, size-pos); err != nil {
+			return err
+		}
+	}
+	glog.V(2).Infof("Built pak from manifest: %d entries, %d relocated\n", len(m.Entries), relocated)
+	return nil
+}
+
+// sameFile 文件是否与清单中记录的数据相同
+func sameFile(file string, size int64, e ManifestEntry) bool {
+	if size != int64(e.Length) {
+		return false
+	}
+	if len(e.SHA256) == 0 {
+		return true
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return false
+	}
+	return hex.EncodeToString(h.Sum(nil)) == e.SHA256
+}
+
+func inSpans(spans []span, offset int64) bool {
+	for _, s := range spans {
+		if offset >= s.start && offset < s.end {
+			return true
+		}
+	}
+	return false
+}
+
+func writeZero(w io.Writer, n int64) error {
+	_, err := io.CopyN(w, zeroReader{}, n)
+	return err
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = 0
+	}
+	return len(b), nil
+}
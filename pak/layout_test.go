@@ -0,0 +1,94 @@
+package pak
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"lucksystem/charset"
+)
+
+func buildFromManifest(t *testing.T, dir string) []byte {
+	t.Helper()
+	m, err := LoadManifest(filepath.Join(dir, ManifestName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err = BuildManifest(buf, m, dir); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestBuildManifestIdentical(t *testing.T) {
+	file := writeTestPak(t, &NewOptions{BlockSize: 16, IDStart: 7, Flags: 4, Named: true},
+		[]string{"a", "bb", "ccc"},
+		[][]byte{[]byte("first entry"), []byte("second"), []byte("third entry data")})
+	// 在Header和文件数据的填充中写入非0数据，模拟原版pak中的残留
+	orig, _ := os.ReadFile(file)
+	p, err := Open(file, charset.UTF_8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig[p.HeaderLength-2] = 0xAA
+	orig[p.Files[1].Offset+p.Files[1].Length+1] = 0x55
+	if err := os.WriteFile(file, orig, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err = Open(file, charset.UTF_8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if _, err := p.Extract(nil, dir, &ExtractOptions{Layout: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got := buildFromManifest(t, dir); !bytes.Equal(got, orig) {
+		t.Fatalf("rebuilt pak differs:\n%x\n%x", got, orig)
+	}
+}
+
+func TestBuildManifestChanged(t *testing.T) {
+	file := writeTestPak(t, &NewOptions{BlockSize: 16, Named: true},
+		[]string{"a", "b", "c"},
+		[][]byte{[]byte("aaaa"), []byte("bbbb"), []byte("cccc")})
+	p, err := Open(file, charset.UTF_8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if _, err := p.Extract(nil, dir, &ExtractOptions{Layout: true}); err != nil {
+		t.Fatal(err)
+	}
+	// a 仍在原块内，b 超出原块需要追加到末尾
+	os.WriteFile(filepath.Join(dir, "a"), []byte("AAAAAAAA"), 0666)
+	os.WriteFile(filepath.Join(dir, "b"), []byte("BBBBBBBBBBBBBBBBBBBBBBBB"), 0666)
+
+	out := filepath.Join(t.TempDir(), "OUT.PAK")
+	if err := os.WriteFile(out, buildFromManifest(t, dir), 0666); err != nil {
+		t.Fatal(err)
+	}
+	result, err := Open(out, charset.UTF_8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problems := result.Verify(); HasErrors(problems) {
+		t.Fatal(problems)
+	}
+	want := map[string]string{"a": "AAAAAAAA", "b": "BBBBBBBBBBBBBBBBBBBBBBBB", "c": "cccc"}
+	for name, data := range want {
+		e, err := result.Get(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(e.Data) != data {
+			t.Errorf("%s = %q, want %q", name, e.Data, data)
+		}
+	}
+	if result.Files[0].Offset != p.Files[0].Offset || result.Files[1].Offset <= p.Files[2].Offset {
+		t.Errorf("unexpected offsets %d %d", result.Files[0].Offset, result.Files[1].Offset)
+	}
+}
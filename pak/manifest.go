@@ -0,0 +1,61 @@
+package pak
+
+import (
+	"encoding/json"
+	"os"
+
+	"lucksystem/charset"
+)
+
+// ManifestName 解包时写入输出目录的清单文件名
+const ManifestName = "manifest.json"
+
+// Manifest 解包清单
+//
+//	Description 记录每个子文件解包后的文件名、类型以及转换后的文件，
+//	  用于将修改后的文件对应回pak中的文件名
+//	  附带 Layout 时还记录原pak的完整结构，可用 BuildManifest 重建出完全相同的pak
+type Manifest struct {
+	Pak     string          `json:"pak"`
+	Charset charset.Charset `json:"charset"`
+	Layout  *Layout         `json:"layout,omitempty"`
+	Entries []ManifestEntry `json:"entries"`
+}
+
+// ManifestEntry 单个子文件的解包信息，路径均为相对清单所在目录、以/分隔
+type ManifestEntry struct {
+	Index     int      `json:"index"`
+	ID        int      `json:"id"`
+	Name      string   `json:"name"`
+	Type      FileType `json:"type"`
+	File      string   `json:"file"`                // 原始数据
+	Converted []string `json:"converted,omitempty"` // 转换后的文件
+	Error     string   `json:"error,omitempty"`     // 转换失败的原因
+
+	// 以下为 Layout 使用的原始位置，Offset为字节
+	Offset uint32 `json:"offset,omitempty"`
+	Length uint32 `json:"length,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// LoadManifest 读取解包清单
+func LoadManifest(file string) (*Manifest, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{}
+	if err = json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Save 保存解包清单
+func (m *Manifest) Save(file string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0666)
+}
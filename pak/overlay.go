@@ -0,0 +1,139 @@
+package pak
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/golang/glog"
+)
+
+// Overlay 按优先级叠加的多个pak
+//
+//	Description Layers[0]为原版pak，之后的pak优先级依次升高，
+//	  查找文件时从优先级最高的pak开始，同名文件以后面的pak为准
+type Overlay struct {
+	Layers []*Pak
+}
+
+// NewOverlay 创建叠加的pak
+//
+//	Description
+//	Param base *Pak 原版pak，优先级最低
+//	Param patches ...*Pak 补丁pak，越靠后优先级越高
+//	Return *Overlay
+func NewOverlay(base *Pak, patches ...*Pak) *Overlay {
+	return &Overlay{Layers: append([]*Pak{base}, patches...)}
+}
+
+// Push 添加一个优先级最高的pak
+func (o *Overlay) Push(p *Pak) {
+	o.Layers = append(o.Layers, p)
+}
+
+// Resolve 查找文件名所在的pak
+//
+//	Description
+//	Receiver o *Overlay
+//	Param name string
+//	Return *Pak 优先级最高的包含该文件的pak，不存在时为nil
+func (o *Overlay) Resolve(name string) *Pak {
+	for i := len(o.Layers) - 1; i >= 0; i-- {
+		if o.Layers[i].CheckName(name) {
+			return o.Layers[i]
+		}
+	}
+	return nil
+}
+
+// ResolveId 查找ID所在的pak
+func (o *Overlay) ResolveId(id int) *Pak {
+	for i := len(o.Layers) - 1; i >= 0; i-- {
+		if o.Layers[i].CheckId(id) {
+			return o.Layers[i]
+		}
+	}
+	return nil
+}
+
+// Get 通过文件名读取文件，返回优先级最高的pak中的数据
+func (o *Overlay) Get(name string) (*Entry, error) {
+	p := o.Resolve(name)
+	if p == nil {
+		return nil, notFound("%s", name)
+	}
+	return p.Get(name)
+}
+
+// GetById 通过ID读取文件，返回优先级最高的pak中的数据
+func (o *Overlay) GetById(id int) (*Entry, error) {
+	p := o.ResolveId(id)
+	if p == nil {
+		return nil, notFound("id %d", id)
+	}
+	return p.GetById(id)
+}
+
+// Flatten 将所有补丁合并到原版pak中
+//
+//	Description 有文件名表时按文件名对应，否则按ID对应。与原版数据相同的文件不会被替换，
+//	  原版中不存在的文件追加到末尾。直接修改 Layers[0]，之后使用 Write 输出
+//	Receiver o *Overlay
+//	Return *Pak 合并后的pak，即 Layers[0]
+//	Return error
+func (o *Overlay) Flatten() (*Pak, error) {
+	if len(o.Layers) == 0 {
+		return nil, errors.New("overlay has no pak")
+	}
+	base := o.Layers[0]
+	named := base.Flags&FlagNamed != 0
+	replaced, added := 0, 0
+	for _, layer := range o.Layers[1:] {
+		if (layer.Flags&FlagNamed != 0) != named {
+			return nil, fmt.Errorf("%s: cannot merge a named pak with an unnamed pak", layer.FileName)
+		}
+		for i, e := range layer.Files {
+			name := e.Name
+			if !named {
+				// 无文件名表时，使用相对base的序号作为文件名
+				name = strconv.Itoa(e.ID - int(base.IDStart))
+			}
+			entry, err := layer.GetByIndex(i)
+			if err != nil {
+				return nil, err
+			}
+			data := entry.Data
+			if !entry.Replace {
+				entry.Data = nil
+			}
+			if !base.CheckName(name) {
+				if !named && name != strconv.Itoa(len(base.Files)) {
+					return nil, fmt.Errorf("%s: entry id %d is not contiguous with the base pak", layer.FileName, e.ID)
+				}
+				if err = base.Add(name, bytes.NewReader(data)); err != nil {
+					return nil, err
+				}
+				added++
+				continue
+			}
+			old, err := base.Get(name)
+			if err != nil {
+				return nil, err
+			}
+			same := bytes.Equal(old.Data, data)
+			if !old.Replace {
+				old.Data = nil
+			}
+			if same {
+				continue
+			}
+			if err = base.Set(name, bytes.NewReader(data)); err != nil {
+				return nil, err
+			}
+			replaced++
+		}
+	}
+	glog.V(2).Infof("Merged %d pak(s): %d replaced, %d added\n", len(o.Layers)-1, replaced, added)
+	return base, nil
+}
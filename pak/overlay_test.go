@@ -0,0 +1,74 @@
+package pak
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"lucksystem/charset"
+)
+
+func TestOverlay(t *testing.T) {
+	opts := &NewOptions{BlockSize: 16, Named: true}
+	base, err := Open(writeTestPak(t, opts, []string{"a", "b", "c"},
+		[][]byte{[]byte("a0"), []byte("b0"), []byte("c0")}), charset.UTF_8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch1, err := Open(writeTestPak(t, opts, []string{"b", "d"},
+		[][]byte{[]byte("b1"), []byte("d1")}), charset.UTF_8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch2, err := Open(writeTestPak(t, opts, []string{"b", "c"},
+		[][]byte{[]byte("b2"), []byte("c0")}), charset.UTF_8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := NewOverlay(base, patch1, patch2)
+	for name, want := range map[string]string{"a": "a0", "b": "b2", "c": "c0", "d": "d1"} {
+		e, err := o.Get(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(e.Data) != want {
+			t.Errorf("Get(%s) = %q, want %q", name, e.Data, want)
+		}
+	}
+	if _, err := o.Get("x"); err == nil {
+		t.Error("expected missing entry")
+	}
+	if e, err := o.GetById(0); err != nil || string(e.Data) != "b2" {
+		t.Errorf("GetById(0) = %v, %v", e, err)
+	}
+
+	merged, err := o.Flatten()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(t.TempDir(), "OUT.PAK")
+	f, _ := os.Create(out)
+	if err = merged.Write(f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	result, err := Open(out, charset.UTF_8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"a", "b", "c", "d"}
+	datas := []string{"a0", "b2", "c0", "d1"}
+	if int(result.FileCount) != len(names) {
+		t.Fatalf("FileCount = %d", result.FileCount)
+	}
+	for i := range names {
+		e, err := result.GetByIndex(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Name != names[i] || string(e.Data) != datas[i] {
+			t.Errorf("entry %d = %s:%q", i, e.Name, e.Data)
+		}
+	}
+}
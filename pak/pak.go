@@ -18,17 +18,17 @@ import (
 )
 
 type Header struct {
-	HeaderLength uint32
-	FileCount    uint32
-	IDStart      uint32 // 图像包中，id段开始
-	BlockSize    uint32
+	HeaderLength uint32 `json:"header_length"`
+	FileCount    uint32 `json:"file_count"`
+	IDStart      uint32 `json:"id_start"` // 图像包中，id段开始
+	BlockSize    uint32 `json:"block_size"`
 
-	Unk2 uint32
-	Unk3 uint32
-	Unk4 uint32
-	Unk5 uint32
+	Unk2 uint32 `json:"unk2"`
+	Unk3 uint32 `json:"unk3"`
+	Unk4 uint32 `json:"unk4"`
+	Unk5 uint32 `json:"unk5"`
 
-	Flags uint32
+	Flags uint32 `json:"flags"`
 	// 4 * 9 = 36
 }
 
@@ -42,25 +42,58 @@ type Entry struct {
 }
 
 type Pak struct {
-	Header    `struct:"-"`
-	Files     []*Entry        `struct:"size=FileCount"`
-	NameMap   map[string]int  `struct:"-"`
-	FileName  string          `struct:"-"`
-	Coding    charset.Charset `struct:"-"`
-	OffsetPos int64           `struct:"-"` // Files 数据开始位置
-	DataPos   int64           `struct:"-"` // Files.Data 数据开始位置
-	Rebuild   bool            `struct:"-"` // 替换数据后，是否需要重构pak
+	Header      `struct:"-"`
+	Files       []*Entry        `struct:"size=FileCount"`
+	NameMap     map[string]int  `struct:"-"`
+	FileName    string          `struct:"-"`
+	Coding      charset.Charset `struct:"-"`
+	OffsetPos   int64           `struct:"-"` // Files 数据开始位置
+	HeaderExtra []byte          `struct:"-"` // Header 与文件名表偏移之间的未知数据，完整重写时保留
+	DataPos     int64           `struct:"-"` // Files.Data 数据开始位置
+	Rebuild     bool            `struct:"-"` // 替换数据后，是否需要重构pak
+	Rewrite     bool            `struct:"-"` // 增删或重命名文件后，需要完整重写pak
+	Jobs        int             `struct:"-"` // 解包、重写时并行处理的文件数，<=1为单线程
+	Progress    ProgressFunc    `struct:"-"` // 解包、重写进度回调
+	Dedup       bool            `struct:"-"` // 完整重写时内容相同的文件共用数据
+	DedupStats  DedupStats      `struct:"-"` // 上次完整重写的去重结果
 }
 
+// LoadPak 载入pak文件
+//
+//	Description 出错时直接退出
+//	Param filename string
+//	Param coding charset.Charset
+//	Return *Pak
+//
+// Deprecated: use Open
 func LoadPak(filename string, coding charset.Charset) *Pak {
 
-	pakFile := &Pak{}
-	pakFile.Load(filename, coding)
+	pakFile, err := Open(filename, coding)
+	if err != nil {
+		glog.Fatalln(err)
+	}
 	return pakFile
 }
-func (p *Pak) Load(filename string, coding charset.Charset) {
+
+// Open 载入pak文件
+//
+//	Description
+//	Param filename string
+//	Param coding charset.Charset
+//	Return *Pak
+//	Return error 文件头损坏时为 ErrBadHeader
+func Open(filename string, coding charset.Charset) (*Pak, error) {
+	pakFile := &Pak{}
+	if err := pakFile.Load(filename, coding); err != nil {
+		return nil, err
+	}
+	return pakFile, nil
+}
+
+func (p *Pak) Load(filename string, coding charset.Charset) error {
 
 	p.Rebuild = false
+	p.Rewrite = false
 	p.FileName = filename
 	p.Coding = coding
 	if len(coding) == 0 {
@@ -68,8 +101,9 @@ func (p *Pak) Load(filename string, coding charset.Charset) {
 	}
 	err := p.open()
 	if err != nil {
-		glog.Fatalln(err)
+		return fmt.Errorf("%s: %w", filename, err)
 	}
+	return nil
 }
 func (p *Pak) open() error {
 	f, err := os.Open(p.FileName)
@@ -79,13 +113,20 @@ func (p *Pak) open() error {
 		return err
 	}
 	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
 
 	headerLenBytes := make([]byte, 4)
 	_, err = f.ReadAt(headerLenBytes, 0)
 	if err != nil {
-		return err
+		return badHeader("file too small")
 	}
 	headerLen := binary.LittleEndian.Uint32(headerLenBytes)
+	if headerLen < headerSize || int64(headerLen) > fi.Size() {
+		return badHeader("header length %d out of range", headerLen)
+	}
 
 	data := make([]byte, headerLen)
 	_, err = f.ReadAt(data, 0)
@@ -97,23 +138,30 @@ func (p *Pak) open() error {
 		glog.V(8).Infoln("restruct.Unpack1", err)
 		return err
 	}
+	if p.BlockSize == 0 {
+		return badHeader("block size is 0")
+	}
+	if int64(p.FileCount)*8 > int64(headerLen) {
+		return badHeader("file count %d does not fit in header", p.FileCount)
+	}
 
-	temp := make([]byte, 4)
 	tempPos := int64(32)
-	_, err = f.ReadAt(temp, tempPos)
-	if err != nil {
-		return err
-	}
-	for binary.LittleEndian.Uint32(temp) != p.HeaderLength/p.BlockSize {
+	for binary.LittleEndian.Uint32(data[tempPos:]) != p.HeaderLength/p.BlockSize {
 		tempPos += 4
-		_, err = f.ReadAt(temp, tempPos)
-		if err != nil {
-			return err
+		if tempPos+4 > int64(headerLen) {
+			return badHeader("offset table not found")
 		}
 	}
+	if tempPos+int64(8*p.FileCount) > int64(headerLen) {
+		return badHeader("offset table overflows header")
+	}
 
 	// 文件偏移 长度读取
 	p.OffsetPos = tempPos
+	p.HeaderExtra = nil
+	if tempPos-4 > headerSize {
+		p.HeaderExtra = append([]byte(nil), data[headerSize:tempPos-4]...)
+	}
 	offData := data[tempPos : tempPos+int64(8*p.FileCount)]
 	err = restruct.Unpack(offData, binary.LittleEndian, p)
 	if err != nil {
@@ -124,16 +172,15 @@ func (p *Pak) open() error {
 	named := (p.Flags & 512) != 0
 	if named {
 
-		_, err = f.ReadAt(temp, tempPos-4)
-		if err != nil {
-			return err
-		}
-		offset := int(binary.LittleEndian.Uint32(temp))
+		offset := int(binary.LittleEndian.Uint32(data[tempPos-4:]))
 		size := 0
 		for _, file := range p.Files {
-			for data[offset+size] != 0x00 {
+			for offset+size < len(data) && data[offset+size] != 0x00 {
 				size++
 			}
+			if offset+size >= len(data) {
+				return badHeader("name table overflows header")
+			}
 			file.Name, err = charset.ToUTF8(p.Coding, data[offset:offset+size])
 
 			if err != nil {
@@ -185,7 +232,7 @@ func (p *Pak) Get(name string) (*Entry, error) {
 
 	id, has := p.NameMap[name]
 	if !has {
-		return nil, errors.New("文件不存在")
+		return nil, notFound("%s", name)
 	}
 	return p.GetById(id)
 }
@@ -197,11 +244,11 @@ func (p *Pak) GetById(id int) (*Entry, error) {
 func (p *Pak) GetByIndex(index int) (*Entry, error) {
 
 	if index < 0 || index >= int(p.FileCount) {
-		return nil, errors.New("文件id错误")
+		return nil, notFound("index %d", index)
 	}
 
 	entry := p.Files[index]
-	if entry.Offset == 0 && entry.Data != nil && len(entry.Data) > 0 {
+	if (entry.Offset == 0 || entry.Replace) && entry.Data != nil && len(entry.Data) > 0 {
 		// 外部数据
 		return entry, nil
 	}
@@ -233,7 +280,7 @@ func (p *Pak) CheckName(name string) bool {
 func (p *Pak) Set(name string, r io.Reader) error {
 	id, has := p.NameMap[name]
 	if !has {
-		return errors.New("文件不存在")
+		return notFound("%s", name)
 	}
 	return p.SetById(id, r)
 }
@@ -249,7 +296,7 @@ func (p *Pak) CheckIndex(index int) bool {
 }
 func (p *Pak) SetByIndex(index int, r io.Reader) error {
 	if index < 0 || index >= int(p.FileCount) {
-		return errors.New("文件id错误")
+		return notFound("index %d", index)
 	}
 	entry := p.Files[index]
 	newData, err := io.ReadAll(r)
@@ -281,6 +328,11 @@ func (p *Pak) SetByIndex(index int, r io.Reader) error {
 //	Param w io.Writer 必须实现 io.WriterAt
 //	Return error
 func (p *Pak) Write(w io.Writer) error {
+	if len(p.FileName) == 0 || p.Rewrite || p.Dedup || p.sharesReplaced() {
+		// 新建的pak没有可复制的原文件；增删文件后文件表大小改变，需要完整重写；
+		// 去重或替换共用数据的文件时，也需要重新分配位置
+		return p.Build(w)
+	}
 
 	oldOffset := make(map[int]uint32, p.FileCount)
 	if p.Rebuild {
@@ -311,8 +363,7 @@ func (p *Pak) Write(w io.Writer) error {
 
 	file, ok := w.(io.WriterAt)
 	if !ok {
-		glog.V(8).Infoln("w.(io.WriterAt)", err)
-		return err
+		return errors.New("pak.Write: writer does not support WriteAt")
 	}
 	// 1. 复制文件全部内容
 	_, err = io.Copy(w, oldFile)
@@ -324,6 +375,7 @@ func (p *Pak) Write(w io.Writer) error {
 	temp := make([]byte, 4)
 	flag := false
 	if p.Rebuild {
+		moved := make([]int, 0)
 		for i, f := range p.Files {
 			if f.Replace { // 资源发生替换，从这里开始写入数据
 				flag = true
@@ -333,15 +385,21 @@ func (p *Pak) Write(w io.Writer) error {
 				file.WriteAt(temp, p.OffsetPos+int64(i*8))
 				binary.LittleEndian.PutUint32(temp, f.Length)
 				file.WriteAt(temp, p.OffsetPos+int64(i*8+4))
-				if f.Replace {
-					file.WriteAt(f.Data, int64(f.Offset))
-				} else {
-					data := make([]byte, f.Length)
-					oldFile.ReadAt(data, int64(oldOffset[i]))
-					file.WriteAt(data, int64(f.Offset))
-				}
+				moved = append(moved, i)
 			}
 		}
+		// 从原文件流式复制，不会载入整个文件
+		err = p.parallel(moved, func(i int) error {
+			f := p.Files[i]
+			if f.Replace {
+				_, err := file.WriteAt(f.Data, int64(f.Offset))
+				return err
+			}
+			return copySection(file, int64(f.Offset), oldFile, int64(oldOffset[i]), int64(f.Length))
+		})
+		if err != nil {
+			return err
+		}
 	} else {
 		for i, f := range p.Files {
 			if f.Replace {
@@ -414,23 +472,38 @@ func (p *Pak) Export(w io.Writer, mode string, value interface{}) error {
 		if _, err = os.Stat(dir); os.IsNotExist(err) {
 			os.Mkdir(dir, os.ModePerm)
 		}
-		fes := p.ReadAll()
-		for _, e := range fes {
-			file := ""
-			line := ""
+		fsys, err := NewFS(p)
+		if err != nil {
+			return err
+		}
+		defer fsys.Close()
+		files := make([]string, len(p.Files))
+		for i, e := range p.Files {
 			if len(e.Name) != 0 {
-
-				file = filepath.Join(dir, e.Name)
-				line = fmt.Sprintf("name:%s,%s\n", e.Name, file)
+				files[i] = filepath.Join(dir, e.Name)
 			} else {
-				file = filepath.Join(dir, strconv.Itoa(e.ID))
-				line = fmt.Sprintf("id:%d,%s\n", e.ID, file)
+				files[i] = filepath.Join(dir, strconv.Itoa(e.ID))
 			}
-			_, err = w.Write([]byte(line))
+		}
+		// 逐个文件流式写出，不会一次载入全部数据
+		err = p.parallel(p.allIndexes(), func(i int) error {
+			r, err := fsys.reader(p.Files[i])
 			if err != nil {
 				return err
 			}
-			err = os.WriteFile(file, e.Data, 0666)
+			return writeFile(files[i], r)
+		})
+		if err != nil {
+			return err
+		}
+		for i, e := range p.Files {
+			line := ""
+			if len(e.Name) != 0 {
+				line = fmt.Sprintf("name:%s,%s\n", e.Name, files[i])
+			} else {
+				line = fmt.Sprintf("id:%d,%s\n", e.ID, files[i])
+			}
+			_, err = w.Write([]byte(line))
 			if err != nil {
 				return err
 			}
@@ -476,7 +549,7 @@ func (p *Pak) Export(w io.Writer, mode string, value interface{}) error {
 //	    mode=="list": r为包含多个文件路径的txt文件，按照Export mode=="all"输出的txt格式
 //	    mode=="dir":  r为空
 //	      opt[1]	dir	string	导入文件目录，按照Export导出的文件名进行匹配。若存在文件名则用文件名匹配，不存在则用ID匹配
-//	Return error
+//	Return error list、dir模式中部分文件失败时返回 *ImportError，其余文件已经导入
 func (p *Pak) Import(r io.Reader, mode string, value interface{}) error {
 
 	var err error
@@ -487,49 +560,28 @@ func (p *Pak) Import(r io.Reader, mode string, value interface{}) error {
 		} else if id, ok := value.(int); ok {
 			err = p.SetById(id, r)
 		} else {
-			glog.Fatalln("输入参数有误")
+			err = fmt.Errorf("pak.Import.file: unsupported value %v", value)
 		}
 	case "list":
-		var fs *os.File
-		var name, file string
-		var id int
-
+		report := &ImportError{}
 		scan := bufio.NewScanner(r)
-		for scan.Scan() {
-			line := scan.Text()
-			param := strings.Split(line, ",")
-
-			if len(param) != 2 {
-				glog.Fatalln("pak.Import.list 输入文件格式有误")
-			}
-			file = param[1]
-			fs, err = os.Open(file)
-			if err != nil {
-				glog.V(2).Infof("%v %s\n", err, file)
+		for line := 1; scan.Scan(); line++ {
+			text := scan.Text()
+			if len(strings.TrimSpace(text)) == 0 {
 				continue
 			}
-			if strings.HasPrefix(param[0], "name") {
-				name = param[0][5:]
-				err = p.Set(name, fs)
-			} else if strings.HasPrefix(param[0], "id") {
-				id, err = strconv.Atoi(param[0][3:])
-				if err != nil {
-					return err
-				}
-				err = p.SetById(id, fs)
-			} else {
-				glog.Fatalln("pak.Import.list 输入文件格式有误")
-			}
-			if err != nil {
-				return err
-			}
-			err = fs.Close()
-			if err != nil {
-				return err
+			if err = p.importLine(text); err != nil {
+				glog.V(2).Infof("line %d: %v\n", line, err)
+				report.Failures = append(report.Failures, ImportFailure{Line: line, Text: text, Err: err})
+				continue
 			}
+			report.Imported++
 		}
 		if err = scan.Err(); err != nil {
-			glog.Fatalln("pak.Import.list 输入文件格式有误")
+			return err
+		}
+		if len(report.Failures) > 0 {
+			return report
 		}
 
 	case "dir":
@@ -538,40 +590,62 @@ func (p *Pak) Import(r io.Reader, mode string, value interface{}) error {
 		if err != nil {
 			return err
 		}
+		report := &ImportError{}
 		for _, file := range files {
 			name := filepath.Base(file)
-			fs, openErr := os.Open(file)
-			if openErr != nil {
-				glog.V(2).Infof("Skip File (open error): %s — %v\n", name, openErr)
-				continue
-			}
 			if p.CheckName(name) {
-				err = p.Set(name, fs)
+				err = p.importFile(file, func(r io.Reader) error { return p.Set(name, r) })
 			} else {
 				id, parseErr := strconv.Atoi(name)
-				if parseErr != nil {
+				if parseErr != nil || !p.CheckId(id) {
 					glog.V(2).Infof("Skip File: %s\n", name)
-					fs.Close()
-					continue
-				}
-				if p.CheckId(id) {
-					err = p.SetById(id, fs)
-				} else {
-					glog.V(2).Infof("Skip File: %s\n", name)
-					fs.Close()
 					continue
 				}
+				err = p.importFile(file, func(r io.Reader) error { return p.SetById(id, r) })
 			}
 			if err != nil {
-				fs.Close()
 				glog.V(2).Infof("%v %s\n", err, file)
-				return err
-			}
-			err = fs.Close()
-			if err != nil {
-				return err
+				report.Failures = append(report.Failures, ImportFailure{Text: file, Err: err})
+				continue
 			}
+			report.Imported++
+		}
+		if len(report.Failures) > 0 {
+			return report
 		}
+		err = nil
+	default:
+		err = errors.New("pak.mode error")
 	}
 	return err
 }
+
+// importLine 导入列表中的一行，格式为 name:文件名,路径 或 id:ID,路径
+func (p *Pak) importLine(line string) error {
+	param := strings.Split(line, ",")
+	if len(param) != 2 {
+		return errors.New("expected name:<name>,<file> or id:<id>,<file>")
+	}
+	switch {
+	case strings.HasPrefix(param[0], "name:"):
+		name := param[0][5:]
+		return p.importFile(param[1], func(r io.Reader) error { return p.Set(name, r) })
+	case strings.HasPrefix(param[0], "id:"):
+		id, err := strconv.Atoi(param[0][3:])
+		if err != nil {
+			return err
+		}
+		return p.importFile(param[1], func(r io.Reader) error { return p.SetById(id, r) })
+	}
+	return errors.New("expected name:<name>,<file> or id:<id>,<file>")
+}
+
+// importFile 打开文件并导入
+func (p *Pak) importFile(file string, set func(r io.Reader) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return set(f)
+}
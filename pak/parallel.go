@@ -0,0 +1,122 @@
+package pak
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// ProgressFunc 进度回调
+//
+//	Description 由 Export、Extract、Write、Build 在每个文件完成后调用，同一时间只会有一个调用
+//	Param done int 已完成的文件数
+//	Param total int 需要处理的文件数
+//	Param e *Entry 刚完成的文件
+type ProgressFunc func(done, total int, e *Entry)
+
+// jobs 并行处理的文件数
+func (p *Pak) jobs() int {
+	if p.Jobs < 1 {
+		return 1
+	}
+	return p.Jobs
+}
+
+// allIndexes 全部文件的序号
+func (p *Pak) allIndexes() []int {
+	indexes := make([]int, len(p.Files))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	return indexes
+}
+
+// parallel 使用 Pak.Jobs 个goroutine处理文件
+//
+//	Description 每个文件完成后调用 Pak.Progress，出现错误后不再开始新的文件
+//	Receiver p *Pak
+//	Param indexes []int 文件序号
+//	Param fn func(i int) error
+//	Return error 第一个错误
+func (p *Pak) parallel(indexes []int, fn func(i int) error) error {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		done     int
+		firstErr error
+	)
+	finish := func(i int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return
+		}
+		done++
+		if p.Progress != nil {
+			p.Progress(done, len(indexes), p.Files[i])
+		}
+	}
+	failed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return firstErr != nil
+	}
+
+	workers := p.jobs()
+	if workers > len(indexes) {
+		workers = len(indexes)
+	}
+	ch := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range ch {
+				finish(i, fn(i))
+			}
+		}()
+	}
+	for _, i := range indexes {
+		if failed() {
+			break
+		}
+		ch <- i
+	}
+	close(ch)
+	wg.Wait()
+	return firstErr
+}
+
+// offsetWriter 从offset开始写入 io.WriterAt
+type offsetWriter struct {
+	w      io.WriterAt
+	offset int64
+}
+
+func (o *offsetWriter) Write(b []byte) (int, error) {
+	n, err := o.w.WriteAt(b, o.offset)
+	o.offset += int64(n)
+	return n, err
+}
+
+// copySection 将src中的一段数据流式复制到w中的offset处
+func copySection(w io.WriterAt, offset int64, src io.ReaderAt, srcOffset, length int64) error {
+	_, err := io.Copy(&offsetWriter{w: w, offset: offset}, io.NewSectionReader(src, srcOffset, length))
+	return err
+}
+
+// writeFile 将r流式写入文件
+func writeFile(file string, r io.Reader) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
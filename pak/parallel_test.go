@@ -0,0 +1,78 @@
+package pak
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-restruct/restruct"
+	"lucksystem/charset"
+)
+
+func TestParallelBuildExtract(t *testing.T) {
+	restruct.EnableExprBeta()
+	names := make([]string, 20)
+	datas := make([][]byte, 20)
+	for i := range names {
+		names[i] = string(rune('a' + i))
+		datas[i] = bytes.Repeat([]byte{byte(i)}, 100*i+1)
+	}
+	build := func(jobs int) ([]byte, int) {
+		p := New(&NewOptions{BlockSize: 64, Named: true})
+		for i, name := range names {
+			p.appendEntry(name, datas[i])
+		}
+		p.Jobs = jobs
+		calls := 0
+		p.Progress = func(done, total int, e *Entry) {
+			calls++
+			if done != calls || total != len(names) {
+				t.Errorf("progress %d/%d after %d calls", done, total, calls)
+			}
+		}
+		file := filepath.Join(t.TempDir(), "TEST.PAK")
+		out, err := os.Create(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = p.Write(out); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		out.Close()
+		data, _ := os.ReadFile(file)
+		return data, calls
+	}
+	serial, _ := build(1)
+	parallel, calls := build(4)
+	if !bytes.Equal(serial, parallel) {
+		t.Fatal("parallel build differs from serial build")
+	}
+	if calls != len(names) {
+		t.Fatalf("progress called %d times", calls)
+	}
+
+	file := filepath.Join(t.TempDir(), "TEST.PAK")
+	if err := os.WriteFile(file, parallel, 0666); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Open(file, charset.UTF_8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Jobs = 4
+	dir := t.TempDir()
+	m, err := p.Extract(nil, dir, &ExtractOptions{Layout: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range names {
+		if m.Entries[i].Name != name {
+			t.Errorf("entry %d = %+v", i, m.Entries[i])
+		}
+		data, _ := os.ReadFile(filepath.Join(dir, name))
+		if !bytes.Equal(data, datas[i]) {
+			t.Errorf("%s: data mismatch", name)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package pak
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Severity 问题等级
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Problem pak检查发现的问题
+type Problem struct {
+	Severity Severity `json:"severity"`
+	Kind     string   `json:"kind"`
+	Index    int      `json:"index"` // 子文件序号，-1表示与子文件无关
+	Name     string   `json:"name,omitempty"`
+	Message  string   `json:"message"`
+}
+
+func (p Problem) String() string {
+	if p.Index < 0 {
+		return fmt.Sprintf("%s: %s: %s", p.Severity, p.Kind, p.Message)
+	}
+	return fmt.Sprintf("%s: %s: [%d] %s: %s", p.Severity, p.Kind, p.Index, p.Name, p.Message)
+}
+
+// HasErrors 是否存在错误等级的问题
+func HasErrors(problems []Problem) bool {
+	for _, pr := range problems {
+		if pr.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify 检查pak文件结构
+//
+//	Description 检查Header、块对齐、子文件重叠、超出文件末尾、未使用的空隙、
+//	  文件名表与 Flags&512 是否一致，以及文件末尾的对齐填充。位置与长度相同的文件视为共用数据
+//	Receiver p *Pak
+//	Return []Problem 没有问题时为空
+func (p *Pak) Verify() []Problem {
+	var problems []Problem
+	add := func(severity Severity, kind string, index int, format string, args ...interface{}) {
+		pr := Problem{Severity: severity, Kind: kind, Index: index, Message: fmt.Sprintf(format, args...)}
+		if index >= 0 {
+			pr.Name = p.Files[index].Name
+		}
+		problems = append(problems, pr)
+	}
+
+	f, err := os.Open(p.FileName)
+	if err != nil {
+		add(SeverityError, "file", -1, "%v", err)
+		return problems
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		add(SeverityError, "file", -1, "%v", err)
+		return problems
+	}
+	fileSize := fi.Size()
+
+	// 1. Header
+	if p.BlockSize == 0 {
+		add(SeverityError, "header", -1, "block size is 0")
+		return problems
+	}
+	if p.HeaderLength%p.BlockSize != 0 {
+		add(SeverityError, "header", -1, "header length %d is not aligned to block size %d", p.HeaderLength, p.BlockSize)
+	}
+	if int64(p.HeaderLength) > fileSize {
+		add(SeverityError, "header", -1, "header length %d exceeds file size %d", p.HeaderLength, fileSize)
+		return problems
+	}
+	if int(p.FileCount) != len(p.Files) {
+		add(SeverityError, "header", -1, "file count %d but %d entries", p.FileCount, len(p.Files))
+	}
+	if p.OffsetPos+8*int64(len(p.Files)) > int64(p.HeaderLength) {
+		add(SeverityError, "header", -1, "offset table at %d overflows header length %d", p.OffsetPos, p.HeaderLength)
+	}
+	if len(p.Files) > 0 && p.Files[0].Offset != p.HeaderLength {
+		add(SeverityError, "header", 0, "first entry offset %d does not follow header length %d", p.Files[0].Offset, p.HeaderLength)
+	}
+
+	// 2. 文件名表
+	problems = append(problems, p.verifyNames(f)...)
+
+	// 3. 每个子文件的对齐与范围
+	lastEnd := int64(p.HeaderLength)
+	for i, e := range p.Files {
+		if e.Offset%p.BlockSize != 0 {
+			add(SeverityError, "alignment", i, "offset %d is not aligned to block size %d", e.Offset, p.BlockSize)
+		}
+		if e.Offset < p.HeaderLength {
+			add(SeverityError, "overlap", i, "offset %d is inside the header (length %d)", e.Offset, p.HeaderLength)
+		}
+		end := int64(e.Offset) + int64(e.Length)
+		if end > fileSize {
+			add(SeverityError, "eof", i, "range %d-%d past end of file %d", e.Offset, end, fileSize)
+		}
+		if end > lastEnd {
+			lastEnd = end
+		}
+	}
+
+	// 4. 按位置排序，检查重叠与空隙
+	order := make([]int, len(p.Files))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return p.Files[order[a]].Offset < p.Files[order[b]].Offset
+	})
+	pos := int64(p.HeaderLength)
+	prev := -1
+	for _, i := range order {
+		e := p.Files[i]
+		start := int64(e.Offset)
+		if prev >= 0 && start == int64(p.Files[prev].Offset) && e.Length == p.Files[prev].Length {
+			// 去重后内容相同的文件共用同一位置
+			continue
+		}
+		if start < pos && prev >= 0 && e.Length > 0 {
+			add(SeverityError, "overlap", i, "range %d-%d overlaps entry [%d] %s ending at %d",
+				start, start+int64(e.Length), prev, p.Files[prev].Name, pos)
+		} else if aligned := int64(align(uint32(pos), p.BlockSize)); start > aligned {
+			add(SeverityWarning, "gap", i, "%d unused bytes before offset %d", start-aligned, start)
+		}
+		if end := start + int64(e.Length); end > pos {
+			pos = end
+			prev = i
+		}
+	}
+
+	// 5. 文件末尾填充
+	alignedEnd := int64(align(uint32(lastEnd), p.BlockSize))
+	if fileSize > alignedEnd {
+		add(SeverityError, "tail", -1, "%d stale bytes after the last entry (file size %d, expected %d)",
+			fileSize-alignedEnd, fileSize, alignedEnd)
+	} else if fileSize < alignedEnd && fileSize >= lastEnd {
+		add(SeverityWarning, "tail", -1, "file size %d is not padded to block size (expected %d)",
+			fileSize, alignedEnd)
+	}
+	return problems
+}
+
+// verifyNames 检查文件名表与 Flags&512 是否一致
+func (p *Pak) verifyNames(f *os.File) []Problem {
+	var problems []Problem
+	add := func(index int, format string, args ...interface{}) {
+		pr := Problem{Severity: SeverityError, Kind: "names", Index: index, Message: fmt.Sprintf(format, args...)}
+		if index >= 0 {
+			pr.Name = p.Files[index].Name
+		}
+		problems = append(problems, pr)
+	}
+	named := p.Flags&FlagNamed != 0
+	seen := make(map[string]int, len(p.Files))
+	for i, e := range p.Files {
+		if named && len(e.Name) == 0 {
+			add(i, "empty name")
+		}
+		if j, ok := seen[e.Name]; ok {
+			add(i, "duplicate name, also used by entry %d", j)
+		}
+		seen[e.Name] = i
+	}
+	if !named || p.OffsetPos < 4 {
+		return problems
+	}
+
+	header := make([]byte, p.HeaderLength)
+	if _, err := f.ReadAt(header, 0); err != nil {
+		add(-1, "read header: %v", err)
+		return problems
+	}
+	tableStart := p.OffsetPos + 8*int64(len(p.Files))
+	offset := int64(binary.LittleEndian.Uint32(header[p.OffsetPos-4:]))
+	if offset < tableStart || offset >= int64(len(header)) {
+		add(-1, "name table offset %d outside header (%d-%d)", offset, tableStart, len(header))
+		return problems
+	}
+	for i := range p.Files {
+		end := offset
+		for end < int64(len(header)) && header[end] != 0 {
+			end++
+		}
+		if end >= int64(len(header)) {
+			add(i, "name table truncated at header length %d", len(header))
+			break
+		}
+		offset = end + 1
+	}
+	return problems
+}
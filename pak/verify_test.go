@@ -0,0 +1,111 @@
+package pak
+
+import (
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"lucksystem/charset"
+)
+
+func verifyKinds(problems []Problem) map[string]bool {
+	kinds := make(map[string]bool)
+	for _, pr := range problems {
+		kinds[pr.Kind] = true
+	}
+	return kinds
+}
+
+func TestVerifyClean(t *testing.T) {
+	file := writeTestPak(t, &NewOptions{BlockSize: 32, Named: true},
+		[]string{"a", "b", "c"}, [][]byte{make([]byte, 40), make([]byte, 3), make([]byte, 64)})
+	p, err := Open(file, charset.UTF_8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problems := p.Verify(); len(problems) != 0 {
+		t.Fatalf("unexpected problems: %v", problems)
+	}
+}
+
+func TestVerifyStaleTail(t *testing.T) {
+	file := writeTestPak(t, &NewOptions{BlockSize: 32, Named: true},
+		[]string{"a"}, [][]byte{make([]byte, 10)})
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(make([]byte, 100))
+	f.Close()
+
+	p, err := Open(file, charset.UTF_8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems := p.Verify()
+	if !verifyKinds(problems)["tail"] || !HasErrors(problems) {
+		t.Fatalf("expected tail error, got %v", problems)
+	}
+}
+
+func TestVerifyOverlapAndEOF(t *testing.T) {
+	file := writeTestPak(t, &NewOptions{BlockSize: 16, Named: true},
+		[]string{"a", "b"}, [][]byte{make([]byte, 40), make([]byte, 8)})
+	p, err := Open(file, charset.UTF_8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(file, os.O_RDWR, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	temp := make([]byte, 4)
+	// b 指向 a 的范围内
+	binary.LittleEndian.PutUint32(temp, p.Files[0].Offset/p.BlockSize+1)
+	f.WriteAt(temp, p.OffsetPos+8)
+	// a 的长度超出文件末尾
+	binary.LittleEndian.PutUint32(temp, 4096)
+	f.WriteAt(temp, p.OffsetPos+4)
+	f.Close()
+
+	p, err = Open(file, charset.UTF_8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := verifyKinds(p.Verify())
+	if !kinds["overlap"] || !kinds["eof"] {
+		t.Fatalf("expected overlap and eof problems, got %v", kinds)
+	}
+}
+
+func TestVerifyGap(t *testing.T) {
+	file := writeTestPak(t, &NewOptions{BlockSize: 16, Named: true},
+		[]string{"a", "b"}, [][]byte{make([]byte, 8), make([]byte, 8)})
+	p, err := Open(file, charset.UTF_8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(file, os.O_RDWR, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	temp := make([]byte, 4)
+	// 缩短 a，留下空隙
+	binary.LittleEndian.PutUint32(temp, 0)
+	f.WriteAt(temp, p.OffsetPos+4)
+	f.Close()
+
+	p, err = Open(file, charset.UTF_8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems := p.Verify()
+	if HasErrors(problems) {
+		t.Fatalf("unexpected errors: %v", problems)
+	}
+	// a 的长度为0，b 前的16字节为空隙
+	if !verifyKinds(problems)["gap"] {
+		t.Fatalf("expected gap warning, got %v", problems)
+	}
+}
@@ -0,0 +1,337 @@
+package patch
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/go-restruct/restruct"
+	"github.com/golang/glog"
+	"lucksystem/pak"
+)
+
+// Magic lpk补丁文件头
+var Magic = []byte{'L', 'P', 'K', 0}
+
+// Version 当前补丁格式版本
+const Version = 2
+
+// 补丁操作类型
+const (
+	OpReplace uint8 = 1 // 替换已有文件数据
+	OpAdd     uint8 = 2 // 在修改后pak中的位置插入新文件
+	OpRemove  uint8 = 3 // 删除文件
+)
+
+// Patch lpk补丁
+//
+//	Description 记录两个pak之间子文件级别的差异，只包含新数据，不包含原pak的数据。
+//	  有文件名表的pak按文件名对应，否则按ID对应
+type Patch struct {
+	Magic    []byte `struct:"size=4"`
+	Version  uint32
+	OrigSize uint64
+	OrigHash []byte `struct:"size=32"` // 原pak的sha256
+	Named    uint8
+	Header   pak.Header // 修改后pak的文件头，应用时使用其中的BlockSize、Unk2-Unk5与Flags
+	Count    uint32
+	Ops      []*Op `struct:"size=Count"`
+}
+
+// Op 单个子文件的差异
+type Op struct {
+	Kind     uint8
+	ID       uint32 // OpAdd时为修改后pak中的ID，其余为原pak中的ID
+	NameLen  uint16
+	Name     []byte `struct:"size=NameLen"` // pak编码前的utf-8文件名
+	OrigHash []byte `struct:"size=32"`      // 原文件数据的sha256，OpAdd时为0
+	Length   uint32
+	Data     []byte `struct:"size=Length"` // 新数据，OpRemove时为空
+}
+
+func (op *Op) String() string {
+	kind := map[uint8]string{OpReplace: "replace", OpAdd: "add", OpRemove: "remove"}[op.Kind]
+	return fmt.Sprintf("%s %d:%s (%d bytes)", kind, op.ID, op.Name, op.Length)
+}
+
+// HashFile 计算文件的sha256
+func HashFile(filename string) (size int64, hash []byte, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err = io.Copy(h, f)
+	if err != nil {
+		return 0, nil, err
+	}
+	return size, h.Sum(nil), nil
+}
+
+func hashData(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+// readEntry 读取子文件数据，读取后释放Entry.Data
+func readEntry(p *pak.Pak, index int) ([]byte, error) {
+	e, err := p.GetByIndex(index)
+	if err != nil {
+		return nil, err
+	}
+	data := e.Data
+	if !e.Replace {
+		e.Data = nil
+	}
+	return data, nil
+}
+
+// Make 对比原pak与修改后的pak，生成补丁
+//
+//	Description 保留的文件在两个pak中的顺序需要相同，新文件记录修改后pak中的ID，
+//	  应用时插入到相同位置
+//	Param orig *pak.Pak 原pak
+//	Param modified *pak.Pak 修改后的pak
+//	Return *Patch
+//	Return error
+func Make(orig, modified *pak.Pak) (*Patch, error) {
+	size, hash, err := HashFile(orig.FileName)
+	if err != nil {
+		return nil, err
+	}
+	named := orig.Flags&pak.FlagNamed != 0
+	if named != (modified.Flags&pak.FlagNamed != 0) {
+		return nil, fmt.Errorf("cannot diff a named pak against an unnamed pak")
+	}
+	if orig.IDStart != modified.IDStart {
+		return nil, fmt.Errorf("cannot diff paks with different id start (%d, %d)", orig.IDStart, modified.IDStart)
+	}
+	pt := &Patch{
+		Magic:    Magic,
+		Version:  Version,
+		OrigSize: uint64(size),
+		OrigHash: hash,
+		Header:   modified.Header,
+	}
+	if named {
+		pt.Named = 1
+	}
+	key := func(e *pak.Entry) string {
+		if named {
+			return e.Name
+		}
+		return strconv.Itoa(e.ID)
+	}
+	origIndex := make(map[string]int, len(orig.Files))
+	for i, e := range orig.Files {
+		origIndex[key(e)] = i
+	}
+	newIndex := make(map[string]int, len(modified.Files))
+	last := -1
+	for i, e := range modified.Files {
+		newIndex[key(e)] = i
+		if j, ok := origIndex[key(e)]; ok {
+			if j < last {
+				return nil, fmt.Errorf("entry %s was moved, reordering is not supported", key(e))
+			}
+			last = j
+		}
+	}
+
+	// 1. 删除
+	for i, e := range orig.Files {
+		if _, ok := newIndex[key(e)]; ok {
+			continue
+		}
+		data, err := readEntry(orig, i)
+		if err != nil {
+			return nil, err
+		}
+		pt.add(&Op{Kind: OpRemove, ID: uint32(e.ID), Name: []byte(e.Name), OrigHash: hashData(data)})
+	}
+	// 2. 替换与添加，按修改后pak的顺序
+	for i, e := range modified.Files {
+		data, err := readEntry(modified, i)
+		if err != nil {
+			return nil, err
+		}
+		j, ok := origIndex[key(e)]
+		if !ok {
+			pt.add(&Op{Kind: OpAdd, ID: uint32(e.ID), Name: []byte(e.Name), OrigHash: make([]byte, 32), Data: data})
+			continue
+		}
+		origData, err := readEntry(orig, j)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(origData, data) {
+			continue
+		}
+		pt.add(&Op{Kind: OpReplace, ID: uint32(orig.Files[j].ID), Name: []byte(e.Name), OrigHash: hashData(origData), Data: data})
+	}
+	return pt, nil
+}
+
+func (pt *Patch) add(op *Op) {
+	op.NameLen = uint16(len(op.Name))
+	op.Length = uint32(len(op.Data))
+	pt.Ops = append(pt.Ops, op)
+	pt.Count = uint32(len(pt.Ops))
+	glog.V(4).Infoln("patch", op)
+}
+
+// Write 写入lpk补丁
+func (pt *Patch) Write(w io.Writer) error {
+	data, err := restruct.Pack(binary.LittleEndian, pt)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Load 读取lpk补丁
+func Load(data []byte) (*Patch, error) {
+	if !bytes.HasPrefix(data, Magic) {
+		return nil, fmt.Errorf("not a LuckSystem patch file")
+	}
+	pt := &Patch{}
+	err := restruct.Unpack(data, binary.LittleEndian, pt)
+	if err != nil {
+		return nil, err
+	}
+	if pt.Version != Version {
+		return nil, fmt.Errorf("unsupported patch version %d", pt.Version)
+	}
+	return pt, nil
+}
+
+// LoadFile 读取lpk补丁文件
+func LoadFile(filename string) (*Patch, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return Load(data)
+}
+
+// Check 检查原pak是否与生成补丁时使用的pak一致
+//
+//	Description 比较整个文件与每个被替换、删除子文件的sha256
+//	Receiver pt *Patch
+//	Param orig *pak.Pak
+//	Return error 不一致时返回错误
+func (pt *Patch) Check(orig *pak.Pak) error {
+	size, hash, err := HashFile(orig.FileName)
+	if err != nil {
+		return err
+	}
+	if uint64(size) != pt.OrigSize || !bytes.Equal(hash, pt.OrigHash) {
+		return fmt.Errorf("%s does not match the original pak of this patch (size %d, expected %d)",
+			orig.FileName, size, pt.OrigSize)
+	}
+	if (orig.Flags&pak.FlagNamed != 0) != (pt.Named != 0) {
+		return fmt.Errorf("%s: name table flag does not match the patch", orig.FileName)
+	}
+	for _, op := range pt.Ops {
+		if op.Kind == OpAdd {
+			continue
+		}
+		e, err := pt.entry(orig, op)
+		if err != nil {
+			return err
+		}
+		data, err := readEntry(orig, e.ID-int(orig.IDStart))
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(hashData(data), op.OrigHash) {
+			return fmt.Errorf("entry %d:%s does not match the original pak of this patch", e.ID, e.Name)
+		}
+	}
+	return nil
+}
+
+// entry 查找补丁操作对应的原文件
+func (pt *Patch) entry(orig *pak.Pak, op *Op) (*pak.Entry, error) {
+	index := int(op.ID) - int(orig.IDStart)
+	if pt.Named != 0 {
+		id, ok := orig.NameMap[string(op.Name)]
+		if !ok {
+			return nil, fmt.Errorf("entry %s not found", op.Name)
+		}
+		index = id - int(orig.IDStart)
+	}
+	if !orig.CheckIndex(index) {
+		return nil, fmt.Errorf("entry id %d not found", op.ID)
+	}
+	return orig.Files[index], nil
+}
+
+// Apply 将补丁应用到原pak
+//
+//	Description 先检查原pak，再通过 pak.Pak 的 Set、Remove、Insert 修改，
+//	  并使用修改后pak的文件头，之后使用 Pak.Write 输出
+//	Receiver pt *Patch
+//	Param orig *pak.Pak
+//	Return error
+func (pt *Patch) Apply(orig *pak.Pak) error {
+	if err := pt.Check(orig); err != nil {
+		return err
+	}
+	// 先替换，按ID删除时需要原顺序
+	for _, op := range pt.Ops {
+		if op.Kind != OpReplace {
+			continue
+		}
+		e, err := pt.entry(orig, op)
+		if err != nil {
+			return err
+		}
+		if err = orig.SetById(e.ID, bytes.NewReader(op.Data)); err != nil {
+			return err
+		}
+	}
+	// 删除，从后向前避免ID变化
+	removes := make([]*pak.Entry, 0)
+	for _, op := range pt.Ops {
+		if op.Kind != OpRemove {
+			continue
+		}
+		e, err := pt.entry(orig, op)
+		if err != nil {
+			return err
+		}
+		removes = append(removes, e)
+	}
+	for i := len(removes) - 1; i >= 0; i-- {
+		if err := orig.Remove(removes[i].Name); err != nil {
+			return err
+		}
+	}
+	// 按修改后pak中的顺序插入
+	for _, op := range pt.Ops {
+		if op.Kind != OpAdd {
+			continue
+		}
+		if err := orig.Insert(int(op.ID)-int(orig.IDStart), string(op.Name), bytes.NewReader(op.Data)); err != nil {
+			return err
+		}
+	}
+	h := &pt.Header
+	if h.BlockSize != orig.BlockSize || h.Unk2 != orig.Unk2 || h.Unk3 != orig.Unk3 ||
+		h.Unk4 != orig.Unk4 || h.Unk5 != orig.Unk5 || h.Flags != orig.Flags {
+		if h.BlockSize == 0 {
+			return fmt.Errorf("patch header has block size 0")
+		}
+		orig.BlockSize, orig.Flags = h.BlockSize, h.Flags
+		orig.Unk2, orig.Unk3, orig.Unk4, orig.Unk5 = h.Unk2, h.Unk3, h.Unk4, h.Unk5
+		orig.Rewrite = true
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package patch
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-restruct/restruct"
+	"lucksystem/charset"
+	"lucksystem/pak"
+)
+
+func createPak(t *testing.T, dir, name string, files [][2]string) string {
+	t.Helper()
+	return createPakBlock(t, dir, name, 16, files)
+}
+
+func createPakBlock(t *testing.T, dir, name string, blockSize uint32, files [][2]string) string {
+	t.Helper()
+	p := pak.New(&pak.NewOptions{BlockSize: blockSize, Named: true})
+	for _, f := range files {
+		if err := p.Add(f[0], strings.NewReader(f[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file := filepath.Join(dir, name)
+	out, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	if err = p.Write(out); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func openPak(t *testing.T, file string) *pak.Pak {
+	t.Helper()
+	p, err := pak.Open(file, charset.UTF_8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestMakeApply(t *testing.T) {
+	restruct.EnableExprBeta()
+	dir := t.TempDir()
+	origFile := createPak(t, dir, "ORIG.PAK", [][2]string{{"a", "aaaa"}, {"b", "bbbb"}, {"c", "cccc"}})
+	newFile := createPak(t, dir, "NEW.PAK", [][2]string{{"a", "aaaa"}, {"c", "CCCCCCCCCCCCCCCCCCCCCCCC"}, {"d", "dd"}})
+
+	pt, err := Make(openPak(t, origFile), openPak(t, newFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt.Count != 3 {
+		t.Fatalf("expected 3 ops, got %v", pt.Ops)
+	}
+	buf := &bytes.Buffer{}
+	if err = pt.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("bbbb")) {
+		t.Fatal("patch contains original data")
+	}
+	loaded, err := Load(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orig := openPak(t, origFile)
+	if err = loaded.Apply(orig); err != nil {
+		t.Fatal(err)
+	}
+	outFile := filepath.Join(dir, "OUT.PAK")
+	out, _ := os.Create(outFile)
+	if err = orig.Write(out); err != nil {
+		t.Fatal(err)
+	}
+	out.Close()
+
+	result := openPak(t, outFile)
+	want := map[string]string{"a": "aaaa", "c": "CCCCCCCCCCCCCCCCCCCCCCCC", "d": "dd"}
+	if int(result.FileCount) != len(want) {
+		t.Fatalf("FileCount = %d", result.FileCount)
+	}
+	for name, data := range want {
+		e, err := result.Get(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(e.Data) != data {
+			t.Fatalf("%s = %q, want %q", name, e.Data, data)
+		}
+	}
+}
+
+func TestApplyRejectsOtherPak(t *testing.T) {
+	restruct.EnableExprBeta()
+	dir := t.TempDir()
+	origFile := createPak(t, dir, "ORIG.PAK", [][2]string{{"a", "aaaa"}})
+	newFile := createPak(t, dir, "NEW.PAK", [][2]string{{"a", "bbbb"}})
+	otherFile := createPak(t, dir, "OTHER.PAK", [][2]string{{"a", "xxxx"}})
+
+	pt, err := Make(openPak(t, origFile), openPak(t, newFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = pt.Apply(openPak(t, otherFile)); err == nil {
+		t.Fatal("expected hash mismatch")
+	}
+}
+
+func TestApplyInsertsInOrder(t *testing.T) {
+	restruct.EnableExprBeta()
+	dir := t.TempDir()
+	origFile := createPak(t, dir, "ORIG.PAK", [][2]string{{"a", "aaaa"}, {"b", "bbbb"}, {"c", "cccc"}})
+	newFile := createPakBlock(t, dir, "NEW.PAK", 32,
+		[][2]string{{"a0", "x"}, {"a", "aaaa"}, {"aa", "yy"}, {"c", "cccc"}, {"d", "z"}})
+
+	pt, err := Make(openPak(t, origFile), openPak(t, newFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err = pt.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := openPak(t, origFile)
+	if err = loaded.Apply(orig); err != nil {
+		t.Fatal(err)
+	}
+	outFile := filepath.Join(dir, "OUT.PAK")
+	out, _ := os.Create(outFile)
+	if err = orig.Write(out); err != nil {
+		t.Fatal(err)
+	}
+	out.Close()
+
+	result := openPak(t, outFile)
+	if result.BlockSize != 32 {
+		t.Errorf("block size %d, want 32", result.BlockSize)
+	}
+	var names []string
+	for _, e := range result.Files {
+		names = append(names, e.Name)
+	}
+	if got := strings.Join(names, ","); got != "a0,a,aa,c,d" {
+		t.Fatalf("entries %s, want a0,a,aa,c,d", got)
+	}
+	if e, _ := result.Get("aa"); e == nil || string(e.Data) != "yy" {
+		t.Errorf("aa = %+v", e)
+	}
+
+	moved := createPak(t, dir, "MOVED.PAK", [][2]string{{"c", "cccc"}, {"a", "aaaa"}})
+	if _, err = Make(openPak(t, origFile), openPak(t, moved)); err == nil {
+		t.Error("expected an error for reordered entries")
+	}
+}
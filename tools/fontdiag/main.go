@@ -35,9 +35,11 @@ func main() {
 		familyPath = filepath.Join(infoDir, familyName)
 	}
 
-	infoPak := pak.LoadPak(filepath.Join(infoDir, infoName), charset.UTF_8)
+	infoPak, err := pak.Open(filepath.Join(infoDir, infoName), charset.UTF_8)
+	check(err)
 	infoPak.ReadAll()
-	familyPak := pak.LoadPak(familyPath, charset.UTF_8)
+	familyPak, err := pak.Open(familyPath, charset.UTF_8)
+	check(err)
 	familyPak.ReadAll()
 	if len(infoPak.Files) != len(familyPak.Files) {
 		fatalf("file count mismatch: %d info files, %d glyph files", len(infoPak.Files), len(familyPak.Files))
@@ -112,7 +112,10 @@ func filterFamilyPaks(familyPaks []string, family string) []string {
 }
 
 func patchSet(set fontSet, chars string, ttfBytes []byte, outputDir string, yOffset int) error {
-	infoPak := pak.LoadPak(set.InfoPak, charset.UTF_8)
+	infoPak, err := pak.Open(set.InfoPak, charset.UTF_8)
+	if err != nil {
+		return err
+	}
 	infoPak.ReadAll()
 	if len(infoPak.Files) == 0 {
 		return fmt.Errorf("empty info pak: %s", set.InfoPak)
@@ -142,7 +145,10 @@ func patchSet(set fontSet, chars string, ttfBytes []byte, outputDir string, yOff
 
 	var patchedInfos [][]byte
 	for _, familyPakName := range set.FamilyPaks {
-		familyPak := pak.LoadPak(familyPakName, charset.UTF_8)
+		familyPak, err := pak.Open(familyPakName, charset.UTF_8)
+		if err != nil {
+			return err
+		}
 		familyPak.ReadAll()
 		if len(familyPak.Files) != len(infoPak.Files) {
 			return fmt.Errorf("file count mismatch: %s has %d, %s has %d",